@@ -0,0 +1,80 @@
+// Package apikeyprom exposes the authorization decisions of the apikey middleware as Prometheus metrics.
+package apikeyprom
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	apikey "github.com/georgepsarakis/chi-api-key-auth"
+)
+
+const defaultNamespace = "chi_api_key_auth"
+
+type Options struct {
+	// Namespace prefixes all metric names; defaults to "chi_api_key_auth".
+	Namespace string
+	// Buckets of the decision duration histogram; defaults to prometheus.DefBuckets.
+	Buckets []float64
+}
+
+// Observer implements apikey.Observer and prometheus.Collector.
+// Register it with a Prometheus registry and set it as apikey.Options.Observer.
+type Observer struct {
+	decisions         *prometheus.CounterVec
+	deprecatedKeyHits *prometheus.CounterVec
+	decisionDurations *prometheus.HistogramVec
+}
+
+var (
+	_ apikey.Observer      = (*Observer)(nil)
+	_ prometheus.Collector = (*Observer)(nil)
+)
+
+func NewObserver(options Options) *Observer {
+	if options.Namespace == "" {
+		options.Namespace = defaultNamespace
+	}
+	if len(options.Buckets) == 0 {
+		options.Buckets = prometheus.DefBuckets
+	}
+	return &Observer{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Name:      "decisions_total",
+			Help:      "Authorization decisions by outcome, key ID and failure reason.",
+		}, []string{"outcome", "key_id", "reason"}),
+		deprecatedKeyHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: options.Namespace,
+			Name:      "deprecated_key_requests_total",
+			Help:      "Requests authorized with a deprecated key, by key ID.",
+		}, []string{"key_id"}),
+		decisionDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: options.Namespace,
+			Name:      "decision_duration_seconds",
+			Help:      "Time spent authorizing requests, by outcome.",
+			Buckets:   options.Buckets,
+		}, []string{"outcome"}),
+	}
+}
+
+func (o *Observer) ObserveAuthorization(_ *http.Request, e apikey.Event) {
+	outcome := e.Outcome.String()
+	o.decisions.WithLabelValues(outcome, e.Key.ID, string(e.Reason)).Inc()
+	if e.Outcome == apikey.OutcomeAuthenticated && e.Key.Deprecated {
+		o.deprecatedKeyHits.WithLabelValues(e.Key.ID).Inc()
+	}
+	o.decisionDurations.WithLabelValues(outcome).Observe(e.Duration.Seconds())
+}
+
+func (o *Observer) Describe(ch chan<- *prometheus.Desc) {
+	o.decisions.Describe(ch)
+	o.deprecatedKeyHits.Describe(ch)
+	o.decisionDurations.Describe(ch)
+}
+
+func (o *Observer) Collect(ch chan<- prometheus.Metric) {
+	o.decisions.Collect(ch)
+	o.deprecatedKeyHits.Collect(ch)
+	o.decisionDurations.Collect(ch)
+}
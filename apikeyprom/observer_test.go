@@ -0,0 +1,55 @@
+package apikeyprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apikey "github.com/georgepsarakis/chi-api-key-auth"
+)
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+
+	observer := NewObserver(Options{})
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(observer))
+
+	handler := apikey.Authorize(apikey.Options{
+		HeaderAuthProvider: apikey.XApiKeyHeader{},
+		KeySetProvider: apikey.StaticKeySet{
+			{ID: "current", Secret: "current-key", Scope: apikey.PermissionScopeReadWrite},
+			{ID: "deprecated", Secret: "deprecated-key", Scope: apikey.PermissionScopeReadWrite, Deprecated: true, ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		Observer: observer,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, requestKey := range []string{"current-key", "deprecated-key", "deprecated-key", "wrong-key", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if requestKey != "" {
+			req.Header.Set(apikey.HeaderNameXApiKey, requestKey)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP chi_api_key_auth_decisions_total Authorization decisions by outcome, key ID and failure reason.
+# TYPE chi_api_key_auth_decisions_total counter
+chi_api_key_auth_decisions_total{key_id="",outcome="unauthenticated",reason="invalid_key"} 1
+chi_api_key_auth_decisions_total{key_id="",outcome="unauthenticated",reason="missing_credential"} 1
+chi_api_key_auth_decisions_total{key_id="current",outcome="authenticated",reason=""} 1
+chi_api_key_auth_decisions_total{key_id="deprecated",outcome="authenticated",reason=""} 2
+# HELP chi_api_key_auth_deprecated_key_requests_total Requests authorized with a deprecated key, by key ID.
+# TYPE chi_api_key_auth_deprecated_key_requests_total counter
+chi_api_key_auth_deprecated_key_requests_total{key_id="deprecated"} 2
+`), "chi_api_key_auth_decisions_total", "chi_api_key_auth_deprecated_key_requests_total"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(observer, "chi_api_key_auth_decision_duration_seconds"))
+}
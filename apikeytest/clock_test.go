@@ -0,0 +1,47 @@
+package apikeytest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	assert.Equal(t, start, c.Now())
+	assert.Equal(t, start, c.Now(), "Should not change on its own")
+
+	c.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), c.Now())
+	c.Advance(-2 * time.Hour)
+	assert.Equal(t, start.Add(-time.Hour), c.Now())
+
+	c.Set(start)
+	assert.Equal(t, start, c.Now())
+}
+
+func TestClock_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	c := NewClock(time.Time{})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Advance(time.Second)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = c.Now()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, time.Time{}.Add(10*time.Second), c.Now())
+}
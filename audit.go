@@ -0,0 +1,42 @@
+package apikey
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// auditObserver logs every authorization decision. Secrets are never logged.
+type auditObserver struct {
+	logger *slog.Logger
+}
+
+var _ Observer = auditObserver{}
+
+func (o auditObserver) ObserveAuthorization(r *http.Request, e Event) {
+	level := slog.LevelInfo
+	if e.Outcome != OutcomeAuthenticated {
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("outcome", e.Outcome.String()),
+		slog.String("method", r.Method),
+		slog.String("route", routePattern(r)),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("key_id", e.Key.ID),
+		slog.String("scope", string(e.Key.Scope)),
+		slog.Bool("deprecated", e.Key.Deprecated),
+	}
+	if e.Reason != "" {
+		attrs = append(attrs, slog.String("reason", string(e.Reason)))
+	}
+	o.logger.LogAttrs(r.Context(), level, "api key authorization", attrs...)
+}
+
+// multiObserver notifies each observer in order.
+type multiObserver []Observer
+
+func (m multiObserver) ObserveAuthorization(r *http.Request, e Event) {
+	for _, o := range m {
+		o.ObserveAuthorization(r, e)
+	}
+}
@@ -0,0 +1,79 @@
+package apikey
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize_AuditLogger(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	router := chi.NewRouter()
+	router.Use(Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider:     StaticKeySet{{ID: "reporting", Secret: "reporting-key", Scope: PermissionScopeReadWrite}},
+		AuditLogger:        logger,
+	}))
+	router.Get("/reports/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, requestKey := range []string{"reporting-key", "wrong-key"} {
+		req := httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set(HeaderNameXApiKey, requestKey)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.NotContains(t, buf.String(), "reporting-key")
+	assert.NotContains(t, buf.String(), "wrong-key")
+
+	var entries []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]any
+		require.NoError(t, dec.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, []map[string]any{
+		{
+			"level":       "INFO",
+			"msg":         "api key authorization",
+			"outcome":     "authenticated",
+			"method":      "GET",
+			"route":       "/reports/{id}",
+			"remote_addr": "192.0.2.1:1234",
+			"key_id":      "reporting",
+			"scope":       "readwrite",
+			"deprecated":  false,
+		},
+		{
+			"level":       "WARN",
+			"msg":         "api key authorization",
+			"outcome":     "unauthenticated",
+			"method":      "GET",
+			"route":       "/reports/{id}",
+			"remote_addr": "192.0.2.1:1234",
+			"key_id":      "",
+			"scope":       "",
+			"deprecated":  false,
+			"reason":      "invalid_key",
+		},
+	}, entries)
+}
@@ -1,9 +1,11 @@
 package apikey
 
 import (
-	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
 	"slices"
+	"time"
 )
 
 type PermissionScope string
@@ -11,12 +13,77 @@ type PermissionScope string
 const PermissionScopeReadonly = PermissionScope("readonly")
 const PermissionScopeReadWrite = PermissionScope("readwrite")
 
+var (
+	ErrInvalidKey        = errors.New("apikey: invalid key")
+	ErrKeyExpired        = errors.New("apikey: key expired")
+	ErrKeyNotYetValid    = errors.New("apikey: key not yet valid")
+	ErrInsufficientScope = errors.New("apikey: insufficient scope")
+	ErrAddressNotAllowed = errors.New("apikey: client address not allowed")
+)
+
+// Outcome classifies the result of an authorization decision.
+type Outcome int
+
+const (
+	// OutcomeUnauthenticated means the request does not carry a valid key.
+	OutcomeUnauthenticated Outcome = iota
+	// OutcomeAuthenticated means the request carries a valid key, permitted for the request.
+	OutcomeAuthenticated
+	// OutcomeForbidden means the request carries a valid key that is not permitted for the request.
+	OutcomeForbidden
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeAuthenticated:
+		return "authenticated"
+	case OutcomeForbidden:
+		return "forbidden"
+	case OutcomeUnauthenticated:
+		return "unauthenticated"
+	default:
+		return "unknown"
+	}
+}
+
+// Decision is the structured result of authorizing a request.
+// Key is set for authenticated and forbidden requests, Reason and Err for rejected ones.
+type Decision struct {
+	Outcome Outcome
+	Key     Key
+	Reason  FailureReason
+	Err     error
+}
+
+func newFailureDecision(key Key, err error) Decision {
+	reason := FailureReasonFromError(err)
+	outcome := OutcomeUnauthenticated
+	if reason == FailureReasonInsufficientScope || reason == FailureReasonAddressNotAllowed {
+		outcome = OutcomeForbidden
+	}
+	return Decision{Outcome: outcome, Key: key, Reason: reason, Err: err}
+}
+
 type Authorizer struct {
 	SecretProvider              SecretProvider
 	DeprecationExpirationPolicy DeprecationExpirationPolicy
-	readOnly                    bool
-	allowedHTTPMethodsOverride  []string
-	availableHTTPMethods        []string
+	// ReadonlyDeprecationExpirationPolicy applies to the deprecated readonly secret instead of
+	// DeprecationExpirationPolicy, when set.
+	ReadonlyDeprecationExpirationPolicy *DeprecationExpirationPolicy
+	// KeySetProvider takes precedence over SecretProvider and DeprecationExpirationPolicy when set.
+	KeySetProvider KeySetProvider
+	// RequiredScopes must all be granted to a key for a request to be accepted.
+	RequiredScopes []PermissionScope
+	// RoutePolicy decides the accepted key scopes for specific routes.
+	RoutePolicy RoutePolicy
+	// ClientIPStrategy determines the client address checked against the allowed networks of keys;
+	// defaults to RemoteAddrClientIP.
+	ClientIPStrategy ClientIPStrategy
+	// Clock provides the current time for validity windows; defaults to the system clock.
+	Clock                      Clock
+	readOnly                   bool
+	allowedHTTPMethodsOverride []string
+	availableHTTPMethods       []string
 }
 
 func NewAuthorizer(secretProvider SecretProvider, deprecationPolicy DeprecationExpirationPolicy, scope PermissionScope, httpMethodsOverride []string) Authorizer {
@@ -50,42 +117,157 @@ func NewReadonlyAuthorizer(provider SecretProvider, httpMethods []string) Author
 }
 
 func (a Authorizer) IsValidRequest(r *http.Request, requestKey string) bool {
-	if requestKey == "" {
-		return false
+	_, err := a.Authenticate(r, requestKey)
+	return err == nil
+}
+
+// Decide authorizes the request key for the request.
+func (a Authorizer) Decide(r *http.Request, requestKey string) Decision {
+	return a.DecideCredential(r, Credential{Secret: requestKey})
+}
+
+// DecideCredential authorizes the credential for the request.
+func (a Authorizer) DecideCredential(r *http.Request, c Credential) Decision {
+	key, err := a.AuthenticateCredential(r, c)
+	if err != nil {
+		return newFailureDecision(key, err)
 	}
-	for _, apiKey := range a.availableAPIKeys(r.Method) {
-		if apiKey == "" {
-			return false
+	return Decision{Outcome: OutcomeAuthenticated, Key: key}
+}
+
+// Authenticate returns the key matching the request key.
+// An error wrapping ErrInvalidKey, ErrKeyNotYetValid, ErrKeyExpired, ErrAddressNotAllowed or ErrInsufficientScope
+// is returned when the request key is unknown, outside the validity window of the key, used from an address outside
+// the allowed networks of the key, or not permitted for the request.
+func (a Authorizer) Authenticate(r *http.Request, requestKey string) (Key, error) {
+	return a.AuthenticateCredential(r, Credential{Secret: requestKey})
+}
+
+// AuthenticateCredential is like Authenticate; when the credential carries a key ID hint matching a known key,
+// the secret is only compared against that key.
+func (a Authorizer) AuthenticateCredential(r *http.Request, c Credential) (Key, error) {
+	if c.Secret == "" {
+		return Key{}, ErrInvalidKey
+	}
+	return a.authenticate(r, c.KeyID, func(key Key) bool {
+		return key.hasCredential() && key.Matches(c.Secret)
+	})
+}
+
+// AuthenticateSignature returns the key the request signature has been computed with.
+// An error wrapping ErrInvalidSignature is returned when no key matches the signature.
+func (a Authorizer) AuthenticateSignature(r *http.Request, s RequestSignature) (Key, error) {
+	key, err := a.authenticate(r, s.KeyID, func(key Key) bool {
+		return s.Verify(key.Secret)
+	})
+	if errors.Is(err, ErrInvalidKey) {
+		return key, ErrInvalidSignature
+	}
+	return key, err
+}
+
+// DecideSignature authorizes the signed request.
+func (a Authorizer) DecideSignature(r *http.Request, s RequestSignature) Decision {
+	key, err := a.AuthenticateSignature(r, s)
+	if err != nil {
+		return newFailureDecision(key, err)
+	}
+	return Decision{Outcome: OutcomeAuthenticated, Key: key}
+}
+
+// authenticate returns the first key accepted by matches that is valid and permitted for the request.
+// The error refers to the first rejected matching key when none is usable.
+func (a Authorizer) authenticate(r *http.Request, keyID string, matches func(Key) bool) (Key, error) {
+	var rule *RouteRule
+	if len(a.RoutePolicy) > 0 {
+		if matched, ok := a.RoutePolicy.Match(r.Method, routePattern(r)); ok {
+			rule = &matched
 		}
-		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(requestKey)) == 1 {
-			return true
+	}
+	now := clockNow(a.Clock)
+	client := a.clientIP(r)
+	// Keep looking for a usable key when a matching key is rejected,
+	// in case the same secret has been assigned to more than one key.
+	match, matchErr := Key{}, ErrInvalidKey
+	keys := a.keySet().Keys()
+	if keyID != "" {
+		if i := slices.IndexFunc(keys, func(k Key) bool { return k.ID == keyID }); i >= 0 {
+			keys = keys[i : i+1]
 		}
 	}
-	return false
+	for _, key := range keys {
+		if !matches(key) {
+			continue
+		}
+		err := a.check(key, r.Method, client, rule, now)
+		if err == nil {
+			return key, nil
+		}
+		if errors.Is(matchErr, ErrInvalidKey) {
+			match, matchErr = key, err
+		}
+	}
+	return match, matchErr
 }
 
-func (a Authorizer) availableAPIKeys(httpMethod string) []string {
-	var keys []string
-	if secret := a.SecretProvider.GetCurrentSecret(); secret != "" {
-		keys = append(keys, secret)
+func (a Authorizer) check(key Key, httpMethod, client string, rule *RouteRule, now time.Time) error {
+	if key.notYetValidAt(now) {
+		return fmt.Errorf("%w: %s", ErrKeyNotYetValid, key.ID)
+	}
+	if key.expiredAt(now) {
+		return fmt.Errorf("%w: %s", ErrKeyExpired, key.ID)
+	}
+	if !key.AllowsAddress(client) {
+		return fmt.Errorf("%w: %s from %s", ErrAddressNotAllowed, key.ID, client)
+	}
+	if !a.permits(key, httpMethod, rule) || !a.hasRequiredScopes(key) {
+		return fmt.Errorf("%w: %s", ErrInsufficientScope, key.ID)
 	}
-	if a.readOnly && slices.Contains(a.availableHTTPMethods, httpMethod) {
-		if secret := a.SecretProvider.GetCurrentReadonlySecret(); secret != "" {
-			keys = append(keys, secret)
+	return nil
+}
+
+func (a Authorizer) hasRequiredScopes(key Key) bool {
+	for _, scope := range a.RequiredScopes {
+		if !key.HasScope(scope) {
+			return false
 		}
 	}
-	if !a.DeprecationExpirationPolicy.Allow() {
-		return keys
+	return true
+}
+
+func (a Authorizer) clientIP(r *http.Request) string {
+	if a.ClientIPStrategy == nil {
+		return RemoteAddrClientIP{}.ClientIP(r)
 	}
+	return a.ClientIPStrategy.ClientIP(r)
+}
 
+func (a Authorizer) keySet() KeySetProvider {
+	if a.KeySetProvider != nil {
+		return a.KeySetProvider
+	}
+	return a.secretProviderKeySet()
+}
+
+// secretProviderKeySet adapts the secret provider of the authorizer to a key set.
+// Readonly authorizers do not accept the deprecated read-write secret.
+func (a Authorizer) secretProviderKeySet() SecretProviderKeySet {
+	keySet := NewSecretProviderKeySet(a.SecretProvider, a.DeprecationExpirationPolicy)
+	keySet.ReadonlyDeprecationExpirationPolicy = a.ReadonlyDeprecationExpirationPolicy
 	if a.readOnly {
-		if secret := a.SecretProvider.GetDeprecatedReadonlySecret(); secret != "" {
-			keys = append(keys, secret)
-		}
-		return keys
+		readonlyPolicy := keySet.readonlyDeprecationExpirationPolicy()
+		keySet.ReadonlyDeprecationExpirationPolicy = &readonlyPolicy
+		keySet.DeprecationExpirationPolicy = DeprecationExpirationPolicy{}
+	}
+	return keySet
+}
+
+func (a Authorizer) permits(key Key, httpMethod string, rule *RouteRule) bool {
+	if rule != nil {
+		return slices.ContainsFunc(rule.Scopes, key.HasScope)
 	}
-	if secret := a.SecretProvider.GetDeprecatedSecret(); secret != "" {
-		keys = append(keys, secret)
+	if key.Scope == PermissionScopeReadonly {
+		return a.readOnly && slices.Contains(a.availableHTTPMethods, httpMethod)
 	}
-	return keys
+	return true
 }
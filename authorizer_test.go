@@ -2,6 +2,7 @@ package apikey
 
 import (
 	"net/http"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -9,7 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
+func TestAuthorizer_IsValidRequest_DeprecationPolicy(t *testing.T) {
 	type fields struct {
 		SecretProvider              SecretProvider
 		DeprecationExpirationPolicy DeprecationExpirationPolicy
@@ -20,10 +21,11 @@ func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
 	t.Setenv("DEPRECATED_API_TOKEN_SECRET", "test-deprecated-current-secret")
 
 	tests := []struct {
-		name       string
-		fields     fields
-		httpMethod string
-		want       []string
+		name        string
+		fields      fields
+		httpMethod  string
+		wantValid   []string
+		wantInvalid []string
 	}{
 		{
 			name: "deprecation policy is valid but deprecated key is not defined",
@@ -35,8 +37,9 @@ func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
 					return p
 				}(),
 			},
-			httpMethod: http.MethodGet,
-			want:       []string{"test-current-secret"},
+			httpMethod:  http.MethodGet,
+			wantValid:   []string{"test-current-secret"},
+			wantInvalid: []string{"test-deprecated-current-secret"},
 		},
 		{
 			name: "deprecation policy is valid and deprecated key is defined",
@@ -49,7 +52,7 @@ func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
 				}(),
 			},
 			httpMethod: http.MethodGet,
-			want:       []string{"test-current-secret", "test-deprecated-current-secret"},
+			wantValid:  []string{"test-current-secret", "test-deprecated-current-secret"},
 		},
 		{
 			name: "deprecation policy is invalid and deprecated key is defined",
@@ -61,8 +64,9 @@ func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
 					return p
 				}(),
 			},
-			httpMethod: http.MethodGet,
-			want:       []string{"test-current-secret"},
+			httpMethod:  http.MethodGet,
+			wantValid:   []string{"test-current-secret"},
+			wantInvalid: []string{"test-deprecated-current-secret"},
 		},
 	}
 	for _, tt := range tests {
@@ -72,7 +76,13 @@ func TestAuthorizer_AvailableAPIKeys(t *testing.T) {
 				DeprecationExpirationPolicy: tt.fields.DeprecationExpirationPolicy,
 				readOnly:                    tt.fields.ReadOnly,
 			}
-			assert.Equalf(t, tt.want, a.availableAPIKeys(tt.httpMethod), "availableAPIKeys()")
+			r := &http.Request{Method: tt.httpMethod}
+			for _, requestKey := range tt.wantValid {
+				assert.Truef(t, a.IsValidRequest(r, requestKey), "IsValidRequest(%s)", requestKey)
+			}
+			for _, requestKey := range tt.wantInvalid {
+				assert.Falsef(t, a.IsValidRequest(r, requestKey), "IsValidRequest(%s)", requestKey)
+			}
 		})
 	}
 }
@@ -319,10 +329,10 @@ func TestAuthorizer_IsValidRequest_EmptyAvailableKeys(t *testing.T) {
 	req := &http.Request{Method: http.MethodGet}
 	result := auth.IsValidRequest(req, "some-key")
 
-	assert.False(t, result, "Should return false when availableAPIKeys returns empty slice")
+	assert.False(t, result, "Should return false when no secret is available")
 }
 
-func TestAuthorizer_AvailableAPIKeys_ReadonlySecret(t *testing.T) {
+func TestAuthorizer_IsValidRequest_ReadonlySecret(t *testing.T) {
 	t.Setenv("READONLY_SECRET", "readonly-key")
 	t.Setenv("DEPRECATED_READONLY_SECRET", "deprecated-readonly-key")
 
@@ -342,12 +352,12 @@ func TestAuthorizer_AvailableAPIKeys_ReadonlySecret(t *testing.T) {
 		availableHTTPMethods:        []string{http.MethodGet},
 	}
 
-	keys := auth.availableAPIKeys(http.MethodGet)
-	assert.Contains(t, keys, "readonly-key", "Should include readonly secret for allowed method")
-	assert.Contains(t, keys, "deprecated-readonly-key", "Should include deprecated readonly secret when policy allows")
+	req := &http.Request{Method: http.MethodGet}
+	assert.True(t, auth.IsValidRequest(req, "readonly-key"), "Should accept readonly secret for allowed method")
+	assert.True(t, auth.IsValidRequest(req, "deprecated-readonly-key"), "Should accept deprecated readonly secret when policy allows")
 }
 
-func TestAuthorizer_AvailableAPIKeys_ReadonlySecret_MethodNotAllowed(t *testing.T) {
+func TestAuthorizer_IsValidRequest_ReadonlySecret_MethodNotAllowed(t *testing.T) {
 	t.Setenv("READONLY_SECRET", "readonly-key")
 
 	provider := NewEnvironmentSecretProvider(EnvironmentSecretProviderSettingNames{
@@ -361,6 +371,207 @@ func TestAuthorizer_AvailableAPIKeys_ReadonlySecret_MethodNotAllowed(t *testing.
 		availableHTTPMethods: []string{http.MethodGet}, // POST not in allowed methods
 	}
 
-	keys := auth.availableAPIKeys(http.MethodPost)
-	assert.NotContains(t, keys, "readonly-key", "Should not include readonly secret for disallowed method")
+	_, err := auth.Authenticate(&http.Request{Method: http.MethodPost}, "readonly-key")
+	require.ErrorIs(t, err, ErrInsufficientScope, "Should not accept readonly secret for disallowed method")
+}
+
+func TestAuthorizer_IsValidRequest_KeySetProvider(t *testing.T) {
+	now := time.Now()
+	keys := StaticKeySet{
+		{ID: "client-a", Secret: "client-a-key", Scope: PermissionScopeReadWrite},
+		{ID: "client-b", Secret: "client-b-key", Scope: PermissionScopeReadWrite},
+		{ID: "dashboard", Secret: "dashboard-key", Scope: PermissionScopeReadonly},
+		{ID: "staged", Secret: "staged-key", Scope: PermissionScopeReadWrite, NotBefore: now.Add(time.Hour)},
+		{ID: "retired", Secret: "retired-key", Scope: PermissionScopeReadWrite, ExpiresAt: now.Add(-time.Hour)},
+	}
+
+	tests := []struct {
+		name       string
+		scope      PermissionScope
+		httpMethod string
+		requestKey string
+		want       bool
+	}{
+		{name: "first key", scope: PermissionScopeReadWrite, httpMethod: http.MethodPost, requestKey: "client-a-key", want: true},
+		{name: "second key", scope: PermissionScopeReadWrite, httpMethod: http.MethodPost, requestKey: "client-b-key", want: true},
+		{name: "readonly key on read-write authorizer", scope: PermissionScopeReadWrite, httpMethod: http.MethodGet, requestKey: "dashboard-key", want: false},
+		{name: "readonly key with allowed method", scope: PermissionScopeReadonly, httpMethod: http.MethodGet, requestKey: "dashboard-key", want: true},
+		{name: "readonly key with disallowed method", scope: PermissionScopeReadonly, httpMethod: http.MethodPost, requestKey: "dashboard-key", want: false},
+		{name: "key not yet valid", scope: PermissionScopeReadWrite, httpMethod: http.MethodGet, requestKey: "staged-key", want: false},
+		{name: "expired key", scope: PermissionScopeReadWrite, httpMethod: http.MethodGet, requestKey: "retired-key", want: false},
+		{name: "unknown key", scope: PermissionScopeReadWrite, httpMethod: http.MethodGet, requestKey: "unknown-key", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthorizer(nil, DeprecationExpirationPolicy{}, tt.scope, nil)
+			a.KeySetProvider = keys
+			assert.Equal(t, tt.want, a.IsValidRequest(&http.Request{Method: tt.httpMethod}, tt.requestKey))
+		})
+	}
+}
+
+func TestAuthorizer_IsValidRequest_RequiredScopes(t *testing.T) {
+	auth := NewAuthorizer(nil, DeprecationExpirationPolicy{}, PermissionScopeReadWrite, nil)
+	auth.KeySetProvider = StaticKeySet{
+		{ID: "billing", Secret: "billing-key", Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"billing:read"}},
+		{ID: "other", Secret: "other-key", Scope: PermissionScopeReadWrite},
+	}
+	auth.RequiredScopes = []PermissionScope{"billing:read"}
+
+	req := &http.Request{Method: http.MethodGet}
+	assert.True(t, auth.IsValidRequest(req, "billing-key"))
+	assert.False(t, auth.IsValidRequest(req, "other-key"), "Should return false when a required scope is missing")
+}
+
+func TestAuthorizer_Authenticate_Errors(t *testing.T) {
+	auth := NewAuthorizer(nil, DeprecationExpirationPolicy{}, PermissionScopeReadonly, nil)
+	auth.KeySetProvider = StaticKeySet{
+		{ID: "dashboard", Secret: "readonly-key", Scope: PermissionScopeReadonly},
+		{ID: "retired", Secret: "retired-key", Scope: PermissionScopeReadWrite, ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "staged", Secret: "staged-key", Scope: PermissionScopeReadWrite, NotBefore: time.Now().Add(time.Hour)},
+		{ID: "retired-duplicate", Secret: "duplicate-key", Scope: PermissionScopeReadWrite, ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "active-duplicate", Secret: "duplicate-key", Scope: PermissionScopeReadWrite},
+	}
+
+	tests := []struct {
+		name       string
+		httpMethod string
+		requestKey string
+		wantKeyID  string
+		wantErr    error
+	}{
+		{name: "valid key", httpMethod: http.MethodGet, requestKey: "readonly-key", wantKeyID: "dashboard"},
+		{name: "unknown key", httpMethod: http.MethodGet, requestKey: "wrong-key", wantErr: ErrInvalidKey},
+		{name: "empty key", httpMethod: http.MethodGet, requestKey: "", wantErr: ErrInvalidKey},
+		{name: "expired key", httpMethod: http.MethodGet, requestKey: "retired-key", wantKeyID: "retired", wantErr: ErrKeyExpired},
+		{name: "key not yet valid", httpMethod: http.MethodGet, requestKey: "staged-key", wantKeyID: "staged", wantErr: ErrKeyNotYetValid},
+		{name: "method not permitted", httpMethod: http.MethodPost, requestKey: "readonly-key", wantKeyID: "dashboard", wantErr: ErrInsufficientScope},
+		{name: "usable key sharing a secret", httpMethod: http.MethodPost, requestKey: "duplicate-key", wantKeyID: "active-duplicate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := auth.Authenticate(&http.Request{Method: tt.httpMethod}, tt.requestKey)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantKeyID, key.ID)
+		})
+	}
+}
+
+func TestAuthorizer_Decide(t *testing.T) {
+	auth := NewAuthorizer(nil, DeprecationExpirationPolicy{}, PermissionScopeReadonly, nil)
+	auth.KeySetProvider = StaticKeySet{{ID: "dashboard", Secret: "readonly-key", Scope: PermissionScopeReadonly}}
+
+	decision := auth.Decide(&http.Request{Method: http.MethodGet}, "readonly-key")
+	assert.Equal(t, OutcomeAuthenticated, decision.Outcome)
+	assert.Equal(t, "dashboard", decision.Key.ID)
+	assert.Empty(t, decision.Reason)
+	require.NoError(t, decision.Err)
+
+	decision = auth.Decide(&http.Request{Method: http.MethodPost}, "readonly-key")
+	assert.Equal(t, OutcomeForbidden, decision.Outcome)
+	assert.Equal(t, "dashboard", decision.Key.ID)
+	assert.Equal(t, FailureReasonInsufficientScope, decision.Reason)
+	require.ErrorIs(t, decision.Err, ErrInsufficientScope)
+
+	decision = auth.Decide(&http.Request{Method: http.MethodGet}, "wrong-key")
+	assert.Equal(t, OutcomeUnauthenticated, decision.Outcome)
+	assert.Equal(t, FailureReasonInvalidKey, decision.Reason)
+	require.ErrorIs(t, decision.Err, ErrInvalidKey)
+}
+
+func TestAuthorizer_Decide_AllowedNetworks(t *testing.T) {
+	auth := NewAuthorizer(nil, DeprecationExpirationPolicy{}, PermissionScopeReadWrite, nil)
+	auth.KeySetProvider = StaticKeySet{{
+		ID:              "partner",
+		Secret:          "partner-key",
+		Scope:           PermissionScopeReadWrite,
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+	}}
+
+	decision := auth.Decide(&http.Request{Method: http.MethodGet, RemoteAddr: "203.0.113.7:4242"}, "partner-key")
+	assert.Equal(t, OutcomeAuthenticated, decision.Outcome)
+
+	decision = auth.Decide(&http.Request{Method: http.MethodGet, RemoteAddr: "198.51.100.1:4242"}, "partner-key")
+	assert.Equal(t, OutcomeForbidden, decision.Outcome)
+	assert.Equal(t, "partner", decision.Key.ID)
+	assert.Equal(t, FailureReasonAddressNotAllowed, decision.Reason)
+	require.ErrorIs(t, decision.Err, ErrAddressNotAllowed)
+
+	auth.ClientIPStrategy = HeaderClientIP{HeaderName: "X-Real-Ip"}
+	r := &http.Request{Method: http.MethodGet, RemoteAddr: "10.0.0.2:4242", Header: http.Header{}}
+	r.Header.Set("X-Real-Ip", "203.0.113.7")
+	decision = auth.Decide(r, "partner-key")
+	assert.Equal(t, OutcomeAuthenticated, decision.Outcome, "Should use the client IP strategy")
+}
+
+func TestAuthorizer_ReadonlyDeprecationExpirationPolicy(t *testing.T) {
+	provider := testSecretProvider{
+		currentSecret:            "current",
+		deprecatedSecret:         "deprecated",
+		currentReadonlySecret:    "readonly",
+		deprecatedReadonlySecret: "readonly-deprecated",
+	}
+	expired, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(-time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	active, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		scope          PermissionScope
+		policy         DeprecationExpirationPolicy
+		readonlyPolicy *DeprecationExpirationPolicy
+		httpMethod     string
+		requestKey     string
+		wantErr        error
+	}{
+		{name: "deprecated readonly key with active readonly policy", scope: PermissionScopeReadonly, policy: expired, readonlyPolicy: &active, httpMethod: http.MethodGet, requestKey: "readonly-deprecated"},
+		{name: "deprecated readonly key with expired readonly policy", scope: PermissionScopeReadonly, policy: active, readonlyPolicy: &expired, httpMethod: http.MethodGet, requestKey: "readonly-deprecated", wantErr: ErrKeyExpired},
+		{name: "deprecated readonly key with shared policy", scope: PermissionScopeReadonly, policy: active, httpMethod: http.MethodGet, requestKey: "readonly-deprecated"},
+		{name: "deprecated key with active policy and expired readonly policy", scope: PermissionScopeReadWrite, policy: active, readonlyPolicy: &expired, httpMethod: http.MethodPost, requestKey: "deprecated"},
+		{name: "deprecated key with expired policy and active readonly policy", scope: PermissionScopeReadWrite, policy: expired, readonlyPolicy: &active, httpMethod: http.MethodPost, requestKey: "deprecated", wantErr: ErrKeyExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewAuthorizer(provider, tt.policy, tt.scope, nil)
+			auth.ReadonlyDeprecationExpirationPolicy = tt.readonlyPolicy
+			_, err := auth.Authenticate(&http.Request{Method: tt.httpMethod}, tt.requestKey)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_Readonly_DeprecatedSecrets(t *testing.T) {
+	provider := testSecretProvider{
+		currentSecret:            "current",
+		deprecatedSecret:         "deprecated",
+		currentReadonlySecret:    "readonly",
+		deprecatedReadonlySecret: "readonly-deprecated",
+	}
+	active, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	auth := NewAuthorizer(provider, active, PermissionScopeReadonly, nil)
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		_, err := auth.Authenticate(&http.Request{Method: method}, "deprecated")
+		require.ErrorIs(t, err, ErrInvalidKey, "Readonly authorizers should never accept the deprecated read-write secret (%s)", method)
+	}
+
+	_, err = auth.Authenticate(&http.Request{Method: http.MethodGet}, "readonly-deprecated")
+	require.NoError(t, err)
+	// Unlike the current readonly secret, the deprecated readonly secret used to be accepted for any HTTP method.
+	// It is now subject to the same method restrictions.
+	_, err = auth.Authenticate(&http.Request{Method: http.MethodPost}, "readonly-deprecated")
+	require.ErrorIs(t, err, ErrInsufficientScope)
+
+	_, err = auth.Authenticate(&http.Request{Method: http.MethodPost}, "current")
+	require.NoError(t, err, "Readonly authorizers should accept the current read-write secret for any method")
 }
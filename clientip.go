@@ -0,0 +1,93 @@
+package apikey
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const HeaderNameXForwardedFor = "X-Forwarded-For"
+
+// ClientIPStrategy determines the IP address of the client of a request.
+type ClientIPStrategy interface {
+	ClientIP(r *http.Request) string
+}
+
+// RemoteAddrClientIP takes the client address from the connection, for servers directly exposed to clients.
+type RemoteAddrClientIP struct{}
+
+var _ ClientIPStrategy = RemoteAddrClientIP{}
+
+func (RemoteAddrClientIP) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+// TrustedProxyClientIP follows the X-Forwarded-For header through the given reverse proxies:
+// the client is the right-most address of the header not belonging to a trusted proxy.
+// The header is ignored for connections that do not come from a trusted proxy.
+type TrustedProxyClientIP struct {
+	TrustedProxies []netip.Prefix
+}
+
+var _ ClientIPStrategy = TrustedProxyClientIP{}
+
+func (s TrustedProxyClientIP) ClientIP(r *http.Request) string {
+	remote := RemoteAddrClientIP{}.ClientIP(r)
+	client, err := netip.ParseAddr(remote)
+	if err != nil || !s.trusted(client) {
+		return remote
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values(HeaderNameXForwardedFor), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(forwarded[i])
+		if entry == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return entry
+		}
+		client = addr.Unmap()
+		if !s.trusted(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (s TrustedProxyClientIP) trusted(addr netip.Addr) bool {
+	for _, prefix := range s.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HeaderClientIP takes the client address from a header set by a load balancer, e.g. X-Real-IP.
+// The load balancer must overwrite the header, otherwise clients can choose their address.
+// The connection address is used when the header is missing.
+type HeaderClientIP struct {
+	HeaderName string
+}
+
+var _ ClientIPStrategy = HeaderClientIP{}
+
+func (s HeaderClientIP) ClientIP(r *http.Request) string {
+	value := strings.TrimSpace(r.Header.Get(s.HeaderName))
+	if value == "" {
+		return RemoteAddrClientIP{}.ClientIP(r)
+	}
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap().String()
+	}
+	return value
+}
@@ -0,0 +1,101 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIPStrategy(t *testing.T) {
+	t.Parallel()
+
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name       string
+		strategy   ClientIPStrategy
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "remote address", strategy: RemoteAddrClientIP{}, remoteAddr: "203.0.113.7:4242", want: "203.0.113.7"},
+		{name: "remote address without port", strategy: RemoteAddrClientIP{}, remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+		{name: "remote IPv4-mapped address", strategy: RemoteAddrClientIP{}, remoteAddr: "[::ffff:203.0.113.7]:4242", want: "203.0.113.7"},
+		{
+			name:       "remote address ignores forwarded header",
+			strategy:   RemoteAddrClientIP{},
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{HeaderNameXForwardedFor: "198.51.100.1"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "trusted proxy",
+			strategy:   TrustedProxyClientIP{TrustedProxies: trustedProxies},
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{HeaderNameXForwardedFor: "192.0.2.9, 198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "untrusted proxy",
+			strategy:   TrustedProxyClientIP{TrustedProxies: trustedProxies},
+			remoteAddr: "203.0.113.7:4242",
+			headers:    map[string]string{HeaderNameXForwardedFor: "198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "load balancer header",
+			strategy:   HeaderClientIP{HeaderName: "X-Real-Ip"},
+			remoteAddr: "10.0.0.2:4242",
+			headers:    map[string]string{"X-Real-Ip": " 198.51.100.1 "},
+			want:       "198.51.100.1",
+		},
+		{name: "missing load balancer header", strategy: HeaderClientIP{HeaderName: "X-Real-Ip"}, remoteAddr: "10.0.0.2:4242", want: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			assert.Equal(t, tt.want, tt.strategy.ClientIP(r))
+		})
+	}
+}
+
+func TestAuthorize_AllowedNetworks(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		KeySetProvider: StaticKeySet{{
+			ID:              "partner",
+			Secret:          "partner-key",
+			Scope:           PermissionScopeReadWrite,
+			AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")},
+		}},
+		HeaderAuthProvider: XApiKeyHeader{},
+		ClientIPStrategy:   TrustedProxyClientIP{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		FailureHandler:     ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(xForwardedFor string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.2:4242"
+		r.Header.Set(HeaderNameXForwardedFor, xForwardedFor)
+		r.Header.Set(HeaderNameXApiKey, "partner-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, send("203.0.113.7").Code)
+
+	w := send("198.51.100.1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"reason":"address_not_allowed"`)
+}
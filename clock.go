@@ -0,0 +1,26 @@
+package apikey
+
+import "time"
+
+// Clock provides the current time to time-based policies, so that they can be tested deterministically
+// (see the apikeytest package).
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the system, used when no Clock is configured.
+type SystemClock struct{}
+
+var _ Clock = SystemClock{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockNow returns the current time of the clock, or the system time when the clock is nil.
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/chi-api-key-auth/apikeytest"
+)
+
+func TestDeprecationExpirationPolicy_AllowAt_Clock(t *testing.T) {
+	t.Parallel()
+
+	rotatedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	policy := NewDeprecationExpirationPolicyFromRotation(rotatedAt, time.Hour)
+	clock := apikeytest.NewClock(rotatedAt.Add(-time.Second))
+
+	assert.False(t, policy.AllowAt(clock.Now()), "Should not allow deprecated keys before the rotation")
+	clock.Advance(time.Second)
+	assert.True(t, policy.AllowAt(clock.Now()))
+	clock.Advance(time.Hour - time.Second)
+	assert.True(t, policy.AllowAt(clock.Now()))
+	clock.Advance(time.Second)
+	assert.False(t, policy.AllowAt(clock.Now()), "Should not allow deprecated keys at the expiration time")
+}
+
+func TestAuthorizer_Clock(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewDeprecationExpirationPolicyFromString("2025-03-01T00:00:00Z")
+	require.NoError(t, err)
+	clock := apikeytest.NewClock(time.Date(2025, time.February, 28, 23, 59, 59, 0, time.UTC))
+	auth := NewAuthorizer(testSecretProvider{currentSecret: "current-key", deprecatedSecret: "deprecated-key"}, policy, PermissionScopeReadWrite, nil)
+	auth.Clock = clock
+	r := &http.Request{Method: http.MethodGet}
+
+	key, err := auth.Authenticate(r, "deprecated-key")
+	require.NoError(t, err, "Should accept the deprecated key before the expiration time of the clock")
+	assert.True(t, key.Deprecated)
+
+	clock.Advance(time.Second)
+	_, err = auth.Authenticate(r, "deprecated-key")
+	require.ErrorIs(t, err, ErrKeyExpired, "Should reject the deprecated key at the expiration time of the clock")
+}
+
+func TestAuthorize_Clock(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewDeprecationExpirationPolicyFromString("2025-03-01T00:00:00Z")
+	require.NoError(t, err)
+	clock := apikeytest.NewClock(time.Date(2025, time.February, 28, 23, 59, 59, 0, time.UTC))
+	handler := Authorize(Options{
+		SecretProvider:              testSecretProvider{currentSecret: "current-key", deprecatedSecret: "deprecated-key"},
+		DeprecationExpirationPolicy: policy,
+		HeaderAuthProvider:          XApiKeyHeader{},
+		Clock:                       clock,
+		FailureHandler:              ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("deprecated-key")
+	assert.Equal(t, http.StatusNoContent, w.Code, "Should accept the deprecated key within the grace period")
+	assert.Equal(t, "@1740787200", w.Header().Get(HeaderNameDeprecation))
+
+	clock.Advance(time.Second)
+	w = send("deprecated-key")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Should reject the deprecated key at the end of the grace period")
+	assert.Contains(t, w.Body.String(), `"reason":"key_expired"`)
+	assert.Equal(t, http.StatusNoContent, send("current-key").Code)
+}
+
+func TestRateLimit_Clock(t *testing.T) {
+	t.Parallel()
+
+	clock := apikeytest.NewClock(time.Unix(1740787200, 0))
+	handler := RateLimit(RateLimitOptions{
+		DefaultRate: Rate{Limit: 1, Period: time.Minute},
+		Clock:       clock,
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(NewPrincipalContext(r.Context(), Principal{KeyID: "current"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, send())
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, http.StatusTooManyRequests, send())
+	clock.Advance(time.Second)
+	assert.Equal(t, http.StatusNoContent, send(), "Should refill the bucket after the period")
+}
@@ -0,0 +1,292 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Credential is the key presented by a request, with an optional key ID hint
+// allowing the Authorizer to look the key up directly.
+type Credential struct {
+	Secret string
+	KeyID  string
+}
+
+// CredentialExtractor can be implemented by a HeaderAuthProvider that provides a key ID hint along with the secret.
+type CredentialExtractor interface {
+	ExtractCredential(r *http.Request) (Credential, error)
+}
+
+func extractCredential(p HeaderAuthProvider, r *http.Request) (Credential, error) {
+	if e, ok := p.(CredentialExtractor); ok {
+		return e.ExtractCredential(r)
+	}
+	secret, err := extractSecret(p, r)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{Secret: secret}, nil
+}
+
+// CredentialStripper can be implemented by a HeaderAuthProvider to remove the credential from the request,
+// before it reaches the handlers, failure handlers and observers.
+type CredentialStripper interface {
+	StripCredential(r *http.Request) *http.Request
+}
+
+func stripCredential(p HeaderAuthProvider, r *http.Request) *http.Request {
+	if stripper, ok := p.(CredentialStripper); ok {
+		return stripper.StripCredential(r)
+	}
+	return r
+}
+
+// Challenger can be implemented by a HeaderAuthProvider to supply the WWW-Authenticate challenge
+// of its authentication scheme, instead of the Bearer challenge of RFC 6750.
+type Challenger interface {
+	Challenge(reason FailureReason) string
+}
+
+func challenge(p HeaderAuthProvider, reason FailureReason) string {
+	if c, ok := p.(Challenger); ok {
+		return c.Challenge(reason)
+	}
+	return bearerChallenge(reason)
+}
+
+const DefaultQueryParamName = "api_key"
+
+// QueryParamAuth reads the key from a URL query parameter (e.g. ?api_key=),
+// for callers that cannot set request headers, such as webhooks.
+type QueryParamAuth struct {
+	HeaderAuthProvider
+	// ParamName defaults to DefaultQueryParamName.
+	ParamName string
+	// StripFromURL removes the parameter from the request URL passed to the next handlers.
+	StripFromURL bool
+}
+
+var (
+	_ HeaderAuthProvider = (*QueryParamAuth)(nil)
+	_ CredentialStripper = (*QueryParamAuth)(nil)
+)
+
+func (q QueryParamAuth) Name() string {
+	if q.ParamName == "" {
+		return DefaultQueryParamName
+	}
+	return q.ParamName
+}
+
+func (q QueryParamAuth) Secret(r *http.Request) (string, bool) {
+	key, err := q.ExtractSecret(r)
+	return key, err == nil
+}
+
+func (q QueryParamAuth) ExtractSecret(r *http.Request) (string, error) {
+	values, ok := r.URL.Query()[q.Name()]
+	if !ok {
+		return "", ErrMissingCredential
+	}
+	key := strings.TrimSpace(values[0])
+	if key == "" {
+		return "", ErrMalformedCredential
+	}
+	return key, nil
+}
+
+func (q QueryParamAuth) StripCredential(r *http.Request) *http.Request {
+	if !q.StripFromURL {
+		return r
+	}
+	query := r.URL.Query()
+	if _, ok := query[q.Name()]; !ok {
+		return r
+	}
+	query.Del(q.Name())
+
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	stripped := r.WithContext(r.Context())
+	stripped.URL = &u
+	if r.RequestURI != "" {
+		stripped.RequestURI = u.RequestURI()
+	}
+	return stripped
+}
+
+const DefaultCookieName = "api_key"
+
+// CookieAuth reads the key from a cookie, e.g. for browser dashboards.
+type CookieAuth struct {
+	HeaderAuthProvider
+	// CookieName defaults to DefaultCookieName.
+	CookieName string
+}
+
+var _ HeaderAuthProvider = (*CookieAuth)(nil)
+
+func (c CookieAuth) Name() string {
+	if c.CookieName == "" {
+		return DefaultCookieName
+	}
+	return c.CookieName
+}
+
+func (c CookieAuth) Secret(r *http.Request) (string, bool) {
+	key, err := c.ExtractSecret(r)
+	return key, err == nil
+}
+
+func (c CookieAuth) ExtractSecret(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(c.Name())
+	if errors.Is(err, http.ErrNoCookie) {
+		return "", ErrMissingCredential
+	}
+	if err != nil {
+		return "", ErrMalformedCredential
+	}
+	key := strings.TrimSpace(cookie.Value)
+	if key == "" {
+		return "", ErrMalformedCredential
+	}
+	return key, nil
+}
+
+var ErrAmbiguousCredential = errors.New("apikey: ambiguous credential")
+
+// MultiHeaderAuthProvider tries each of the providers in order and returns the first credential found,
+// e.g. to accept both X-Api-Key and Authorization: Bearer during a client migration.
+type MultiHeaderAuthProvider struct {
+	HeaderAuthProvider
+	Providers []HeaderAuthProvider
+	// RejectAmbiguous rejects requests presenting different credentials through more than one provider,
+	// with ErrAmbiguousCredential, instead of picking the first one.
+	RejectAmbiguous bool
+}
+
+var (
+	_ HeaderAuthProvider  = (*MultiHeaderAuthProvider)(nil)
+	_ CredentialExtractor = (*MultiHeaderAuthProvider)(nil)
+	_ CredentialStripper  = (*MultiHeaderAuthProvider)(nil)
+	_ Challenger          = (*MultiHeaderAuthProvider)(nil)
+)
+
+func (m MultiHeaderAuthProvider) Name() string {
+	names := make([]string, 0, len(m.Providers))
+	for _, p := range m.Providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (m MultiHeaderAuthProvider) Secret(r *http.Request) (string, bool) {
+	key, err := m.ExtractSecret(r)
+	return key, err == nil
+}
+
+func (m MultiHeaderAuthProvider) ExtractSecret(r *http.Request) (string, error) {
+	c, err := m.ExtractCredential(r)
+	return c.Secret, err
+}
+
+func (m MultiHeaderAuthProvider) ExtractCredential(r *http.Request) (Credential, error) {
+	var found Credential
+	extractErr := ErrMissingCredential
+	for _, p := range m.Providers {
+		c, err := extractCredential(p, r)
+		if err != nil {
+			if errors.Is(err, ErrMalformedCredential) && errors.Is(extractErr, ErrMissingCredential) {
+				extractErr = err
+			}
+			continue
+		}
+		if found.Secret == "" {
+			found = c
+			if !m.RejectAmbiguous {
+				return found, nil
+			}
+			continue
+		}
+		if c != found {
+			return Credential{}, ErrAmbiguousCredential
+		}
+	}
+	if found.Secret != "" {
+		return found, nil
+	}
+	return Credential{}, extractErr
+}
+
+func (m MultiHeaderAuthProvider) StripCredential(r *http.Request) *http.Request {
+	for _, p := range m.Providers {
+		r = stripCredential(p, r)
+	}
+	return r
+}
+
+// Challenge returns the distinct challenges of the providers, so that clients can pick a supported scheme.
+func (m MultiHeaderAuthProvider) Challenge(reason FailureReason) string {
+	challenges := make([]string, 0, len(m.Providers))
+	for _, p := range m.Providers {
+		if c := challenge(p, reason); !slices.Contains(challenges, c) {
+			challenges = append(challenges, c)
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
+
+const DefaultBasicAuthRealm = "api"
+
+// BasicAuthHeader reads the key from the password of an HTTP Basic Authorization header,
+// for integrations that only support Basic authentication.
+// The username, when not empty, is used as a key ID hint.
+type BasicAuthHeader struct {
+	HeaderAuthProvider
+	// Realm is sent in the Basic challenge of unauthenticated responses; defaults to DefaultBasicAuthRealm.
+	Realm string
+}
+
+var (
+	_ HeaderAuthProvider  = (*BasicAuthHeader)(nil)
+	_ CredentialExtractor = (*BasicAuthHeader)(nil)
+	_ Challenger          = (*BasicAuthHeader)(nil)
+)
+
+func (h BasicAuthHeader) Name() string {
+	return HeaderNameAuthorization
+}
+
+func (h BasicAuthHeader) Secret(r *http.Request) (string, bool) {
+	c, err := h.ExtractCredential(r)
+	return c.Secret, err == nil
+}
+
+func (h BasicAuthHeader) ExtractSecret(r *http.Request) (string, error) {
+	c, err := h.ExtractCredential(r)
+	return c.Secret, err
+}
+
+func (h BasicAuthHeader) ExtractCredential(r *http.Request) (Credential, error) {
+	if r.Header.Get(h.Name()) == "" {
+		return Credential{}, ErrMissingCredential
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok || password == "" {
+		return Credential{}, ErrMalformedCredential
+	}
+	return Credential{Secret: password, KeyID: username}, nil
+}
+
+// Challenge returns the Basic challenge of RFC 7617, prompting clients to send their credentials.
+func (h BasicAuthHeader) Challenge(FailureReason) string {
+	realm := h.Realm
+	if realm == "" {
+		realm = DefaultBasicAuthRealm
+	}
+	return `Basic realm="` + quotedStringEscaper.Replace(realm) + `"`
+}
+
+var quotedStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`) //nolint:gochecknoglobals
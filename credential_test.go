@@ -0,0 +1,262 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryParamAuth_ExtractSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		provider   QueryParamAuth
+		target     string
+		wantSecret string
+		wantErr    error
+	}{
+		{name: "default parameter", target: "/hook?api_key=test-key-123", wantSecret: "test-key-123"},
+		{name: "custom parameter", provider: QueryParamAuth{ParamName: "token"}, target: "/hook?token=test-key-123", wantSecret: "test-key-123"},
+		{name: "missing parameter", target: "/hook?other=value", wantErr: ErrMissingCredential},
+		{name: "empty parameter", target: "/hook?api_key=", wantErr: ErrMalformedCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			secret, err := tt.provider.ExtractSecret(httptest.NewRequest(http.MethodPost, tt.target, nil))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSecret, secret)
+		})
+	}
+}
+
+func TestQueryParamAuth_StripCredential(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/hook?event=created&api_key=test-key-123", nil)
+
+	assert.Same(t, r, QueryParamAuth{}.StripCredential(r), "Should not strip unless enabled")
+
+	stripped := QueryParamAuth{StripFromURL: true}.StripCredential(r)
+	assert.Equal(t, "event=created", stripped.URL.RawQuery)
+	assert.Equal(t, "/hook?event=created", stripped.RequestURI)
+	assert.Equal(t, "event=created&api_key=test-key-123", r.URL.RawQuery, "Should not modify the original request")
+}
+
+func TestAuthorize_QueryParamAuth_StripFromURL(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: QueryParamAuth{StripFromURL: true},
+		KeySetProvider:     StaticKeySet{{ID: "webhook", Secret: "test-key-123", Scope: PermissionScopeReadWrite}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "event=created", r.URL.RawQuery)
+		assert.NotContains(t, r.RequestURI, "test-key-123")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hook?event=created&api_key=test-key-123", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestCookieAuth_ExtractSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		provider   CookieAuth
+		cookie     *http.Cookie
+		wantSecret string
+		wantErr    error
+	}{
+		{name: "default cookie", cookie: &http.Cookie{Name: DefaultCookieName, Value: "test-key-123"}, wantSecret: "test-key-123"},
+		{name: "custom cookie", provider: CookieAuth{CookieName: "session_key"}, cookie: &http.Cookie{Name: "session_key", Value: "test-key-123"}, wantSecret: "test-key-123"},
+		{name: "missing cookie", cookie: &http.Cookie{Name: "other", Value: "value"}, wantErr: ErrMissingCredential},
+		{name: "empty cookie", cookie: &http.Cookie{Name: DefaultCookieName, Value: ""}, wantErr: ErrMalformedCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(tt.cookie)
+			secret, err := tt.provider.ExtractSecret(r)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSecret, secret)
+		})
+	}
+}
+
+func TestMultiHeaderAuthProvider_ExtractSecret(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		rejectAmbiguous bool
+		xAPIKey         string
+		authorization   string
+		wantSecret      string
+		wantErr         error
+	}{
+		{name: "first provider", xAPIKey: "legacy-key", wantSecret: "legacy-key"},
+		{name: "second provider", authorization: "Bearer new-key", wantSecret: "new-key"},
+		{name: "first provider wins", xAPIKey: "legacy-key", authorization: "Bearer new-key", wantSecret: "legacy-key"},
+		{name: "conflicting credentials", rejectAmbiguous: true, xAPIKey: "legacy-key", authorization: "Bearer new-key", wantErr: ErrAmbiguousCredential},
+		{name: "identical credentials", rejectAmbiguous: true, xAPIKey: "same-key", authorization: "Bearer same-key", wantSecret: "same-key"},
+		{name: "single credential", rejectAmbiguous: true, authorization: "Bearer new-key", wantSecret: "new-key"},
+		{name: "malformed credential", authorization: "Basic dXNlcjpwYXNz", wantErr: ErrMalformedCredential},
+		{name: "missing credential", wantErr: ErrMissingCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			provider := MultiHeaderAuthProvider{
+				Providers:       []HeaderAuthProvider{XApiKeyHeader{}, AuthorizationHeader{}},
+				RejectAmbiguous: tt.rejectAmbiguous,
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.xAPIKey != "" {
+				r.Header.Set(HeaderNameXApiKey, tt.xAPIKey)
+			}
+			if tt.authorization != "" {
+				r.Header.Set(HeaderNameAuthorization, tt.authorization)
+			}
+
+			secret, err := provider.ExtractSecret(r)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSecret, secret)
+		})
+	}
+}
+
+func TestMultiHeaderAuthProvider_Name(t *testing.T) {
+	t.Parallel()
+
+	provider := MultiHeaderAuthProvider{Providers: []HeaderAuthProvider{XApiKeyHeader{}, AuthorizationHeader{}}}
+	assert.Equal(t, "X-Api-Key, Authorization", provider.Name())
+}
+
+func TestMultiHeaderAuthProvider_Challenge(t *testing.T) {
+	t.Parallel()
+
+	provider := MultiHeaderAuthProvider{Providers: []HeaderAuthProvider{XApiKeyHeader{}, AuthorizationHeader{}, BasicAuthHeader{Realm: "legacy"}}}
+	assert.Equal(t, `Bearer error="invalid_token", Basic realm="legacy"`, provider.Challenge(FailureReasonInvalidKey))
+}
+
+func TestAuthorize_MultiHeaderAuthProvider_Ambiguous(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: MultiHeaderAuthProvider{
+			Providers:       []HeaderAuthProvider{XApiKeyHeader{}, QueryParamAuth{StripFromURL: true}},
+			RejectAmbiguous: true,
+		},
+		KeySetProvider: StaticKeySet{{ID: "current", Secret: "current-key", Scope: PermissionScopeReadWrite}},
+		FailureHandler: ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/?api_key=current-key", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/?api_key=current-key", nil)
+	r.Header.Set(HeaderNameXApiKey, "other-key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"reason":"ambiguous_credential"`)
+}
+
+func TestBasicAuthHeader_ExtractCredential(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		authorization  string
+		username       string
+		password       string
+		wantCredential Credential
+		wantErr        error
+	}{
+		{name: "username and password", username: "billing", password: "test-key-123", wantCredential: Credential{Secret: "test-key-123", KeyID: "billing"}},
+		{name: "password only", password: "test-key-123", wantCredential: Credential{Secret: "test-key-123"}},
+		{name: "missing header", wantErr: ErrMissingCredential},
+		{name: "empty password", username: "billing", wantErr: ErrMalformedCredential},
+		{name: "other scheme", authorization: "Bearer test-key-123", wantErr: ErrMalformedCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			switch {
+			case tt.authorization != "":
+				r.Header.Set(HeaderNameAuthorization, tt.authorization)
+			case tt.username != "" || tt.password != "":
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+
+			c, err := BasicAuthHeader{}.ExtractCredential(r)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantCredential, c)
+		})
+	}
+}
+
+func TestAuthorize_BasicAuthHeader(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: BasicAuthHeader{},
+		KeySetProvider: StaticKeySet{
+			{ID: "billing", Secret: "billing-key", Scope: PermissionScopeReadWrite},
+			{ID: "reporting", Secret: "reporting-key", Scope: PermissionScopeReadWrite},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		_, _ = w.Write([]byte(p.KeyID))
+	}))
+
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		wantStatus    int
+		wantKeyID     string
+		wantChallenge string
+	}{
+		{name: "matching key ID", username: "billing", password: "billing-key", wantStatus: http.StatusOK, wantKeyID: "billing"},
+		{name: "key of another ID", username: "billing", password: "reporting-key", wantStatus: http.StatusUnauthorized, wantChallenge: `Basic realm="api"`},
+		{name: "missing credential", wantStatus: http.StatusUnauthorized, wantChallenge: `Basic realm="api"`},
+		{name: "unknown key ID", username: "legacy", password: "reporting-key", wantStatus: http.StatusOK, wantKeyID: "reporting"},
+		{name: "without key ID", password: "reporting-key", wantStatus: http.StatusOK, wantKeyID: "reporting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.username != "" || tt.password != "" {
+				r.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantChallenge, rec.Header().Get("WWW-Authenticate"))
+			if tt.wantKeyID != "" {
+				assert.Equal(t, tt.wantKeyID, rec.Body.String())
+			}
+		})
+	}
+}
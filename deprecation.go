@@ -1,16 +1,36 @@
 package apikey
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 )
 
+// processStartTime is the reference time of grace periods counted from process start.
+var processStartTime = time.Now() //nolint:gochecknoglobals
+
+// DeprecationExpirationPolicy allows deprecated keys until an expiration time.
+// When it also carries a start time, deprecated keys are only allowed from that time on,
+// e.g. within [rotatedAt, rotatedAt+gracePeriod).
+// Authorizers check the grace period against their own Clock (Options.Clock, Authorizer.Clock),
+// which can be passed to AllowAt.
 type DeprecationExpirationPolicy struct {
+	startAt  time.Time
 	expireAt time.Time
 }
 
+// Allow reports whether deprecated keys are allowed at the current system time.
 func (p DeprecationExpirationPolicy) Allow() bool {
-	return !p.expireAt.IsZero() && time.Now().Before(p.expireAt)
+	return p.AllowAt(time.Now())
+}
+
+// AllowAt reports whether deprecated keys are allowed at the given time, e.g. the time of a Clock.
+func (p DeprecationExpirationPolicy) AllowAt(now time.Time) bool {
+	if !p.startAt.IsZero() && now.Before(p.startAt) {
+		return false
+	}
+	return !p.expireAt.IsZero() && now.Before(p.expireAt)
 }
 
 func NewDeprecationExpirationPolicyFromEnvironment(variableName string) (DeprecationExpirationPolicy, error) {
@@ -30,3 +50,75 @@ func NewDeprecationExpirationPolicyFromString(datetime string) (DeprecationExpir
 		expireAt: expirationTime,
 	}, nil
 }
+
+// NewDeprecationExpirationPolicyFromRotation allows deprecated keys within [rotatedAt, rotatedAt+gracePeriod).
+func NewDeprecationExpirationPolicyFromRotation(rotatedAt time.Time, gracePeriod time.Duration) DeprecationExpirationPolicy {
+	return DeprecationExpirationPolicy{
+		startAt:  rotatedAt,
+		expireAt: rotatedAt.Add(gracePeriod),
+	}
+}
+
+// NewDeprecationExpirationPolicyFromRotationEnvironment reads the rotation time (RFC 3339)
+// and the grace period (e.g. "72h") from the given environment variables.
+func NewDeprecationExpirationPolicyFromRotationEnvironment(rotatedAtVariableName, gracePeriodVariableName string) (DeprecationExpirationPolicy, error) {
+	rotatedAt, err := time.Parse(time.RFC3339, os.Getenv(rotatedAtVariableName))
+	if err != nil {
+		return DeprecationExpirationPolicy{}, err
+	}
+	gracePeriod, err := parseGracePeriod(os.Getenv(gracePeriodVariableName))
+	if err != nil {
+		return DeprecationExpirationPolicy{}, err
+	}
+	return NewDeprecationExpirationPolicyFromRotation(rotatedAt, gracePeriod), nil
+}
+
+// NewDeprecationExpirationPolicyFromDuration allows deprecated keys for the grace period, counted from process start.
+func NewDeprecationExpirationPolicyFromDuration(gracePeriod time.Duration) DeprecationExpirationPolicy {
+	return DeprecationExpirationPolicy{
+		expireAt: processStartTime.Add(gracePeriod),
+	}
+}
+
+func NewDeprecationExpirationPolicyFromDurationEnvironment(variableName string) (DeprecationExpirationPolicy, error) {
+	return NewDeprecationExpirationPolicyFromDurationString(os.Getenv(variableName))
+}
+
+// NewDeprecationExpirationPolicyFromDurationString parses a grace period such as "72h", counted from process start.
+func NewDeprecationExpirationPolicyFromDurationString(gracePeriod string) (DeprecationExpirationPolicy, error) {
+	d, err := parseGracePeriod(gracePeriod)
+	if err != nil {
+		return DeprecationExpirationPolicy{}, err
+	}
+	return NewDeprecationExpirationPolicyFromDuration(d), nil
+}
+
+// NewDeprecationExpirationPolicyFromUnix allows deprecated keys until the given Unix time, in seconds.
+func NewDeprecationExpirationPolicyFromUnix(seconds int64) DeprecationExpirationPolicy {
+	return DeprecationExpirationPolicy{
+		expireAt: time.Unix(seconds, 0),
+	}
+}
+
+func NewDeprecationExpirationPolicyFromUnixEnvironment(variableName string) (DeprecationExpirationPolicy, error) {
+	return NewDeprecationExpirationPolicyFromUnixString(os.Getenv(variableName))
+}
+
+func NewDeprecationExpirationPolicyFromUnixString(seconds string) (DeprecationExpirationPolicy, error) {
+	s, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return DeprecationExpirationPolicy{}, err
+	}
+	return NewDeprecationExpirationPolicyFromUnix(s), nil
+}
+
+func parseGracePeriod(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("apikey: negative grace period %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,32 @@
+package apikey
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderNameDeprecation = "Deprecation"
+	HeaderNameSunset      = "Sunset"
+)
+
+// setDeprecationHeaders signals the use of a deprecated key with the Deprecation (RFC 9745)
+// and Sunset (RFC 8594) response headers. The Sunset header carries the expiration time of the key;
+// the Deprecation header carries the start of its validity window when known, otherwise its expiration time.
+func setDeprecationHeaders(w http.ResponseWriter, key Key, now time.Time) {
+	if !key.Deprecated {
+		return
+	}
+	deprecatedAt := key.ExpiresAt
+	if !key.NotBefore.IsZero() {
+		deprecatedAt = key.NotBefore
+	}
+	if deprecatedAt.IsZero() {
+		deprecatedAt = now
+	}
+	w.Header().Set(HeaderNameDeprecation, "@"+strconv.FormatInt(deprecatedAt.Unix(), 10))
+	if !key.ExpiresAt.IsZero() {
+		w.Header().Set(HeaderNameSunset, key.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,101 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDeprecationHeaders(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	rotatedAt := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		key             Key
+		wantDeprecation string
+		wantSunset      string
+	}{
+		{
+			name: "current key",
+			key:  Key{ExpiresAt: expiresAt},
+		},
+		{
+			name:            "deprecated key with expiration time",
+			key:             Key{Deprecated: true, ExpiresAt: expiresAt},
+			wantDeprecation: "@1743465600",
+			wantSunset:      "Tue, 01 Apr 2025 00:00:00 GMT",
+		},
+		{
+			name:            "deprecated key with validity window",
+			key:             Key{Deprecated: true, NotBefore: rotatedAt, ExpiresAt: expiresAt},
+			wantDeprecation: "@1740787200",
+			wantSunset:      "Tue, 01 Apr 2025 00:00:00 GMT",
+		},
+		{
+			name:            "deprecated key without expiration time",
+			key:             Key{Deprecated: true},
+			wantDeprecation: "@1740830400",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			setDeprecationHeaders(rec, tt.key, now)
+			assert.Equal(t, tt.wantDeprecation, rec.Header().Get(HeaderNameDeprecation))
+			assert.Equal(t, tt.wantSunset, rec.Header().Get(HeaderNameSunset))
+		})
+	}
+}
+
+func TestAPITokenAuth_DeprecatedKeyHeaders(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	policy, err := NewDeprecationExpirationPolicyFromString(expireAt.Format(time.RFC3339))
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		SecretProvider: &testSecretProvider{
+			currentSecret:    "current-key",
+			deprecatedSecret: "deprecated-key",
+		},
+		DeprecationExpirationPolicy: policy,
+	}))
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	do := func(requestKey string) *http.Response {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/", nil)
+		require.NoError(t, err)
+		req.Header.Set(HeaderNameXApiKey, requestKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := do("deprecated-key")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, expireAt.Format(http.TimeFormat), resp.Header.Get(HeaderNameSunset))
+	assert.NotEmpty(t, resp.Header.Get(HeaderNameDeprecation))
+
+	resp = do("current-key")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(HeaderNameSunset))
+	assert.Empty(t, resp.Header.Get(HeaderNameDeprecation))
+}
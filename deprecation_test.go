@@ -2,11 +2,16 @@ package apikey
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/chi-api-key-auth/apikeytest"
 )
 
 func TestDeprecationExpirationPolicy_Allow(t *testing.T) {
@@ -81,3 +86,135 @@ func TestDeprecationExpirationPolicyFromEnvironment(t *testing.T) {
 		require.Error(t, err, "Should return error for nonexistent environment variable")
 	}
 }
+
+func TestNewDeprecationExpirationPolicyFromRotation(t *testing.T) {
+	t.Parallel()
+
+	rotatedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	p := NewDeprecationExpirationPolicyFromRotation(rotatedAt, 72*time.Hour)
+	assert.Equal(t, rotatedAt, p.startAt)
+	assert.Equal(t, rotatedAt.Add(72*time.Hour), p.expireAt)
+
+	assert.True(t, NewDeprecationExpirationPolicyFromRotation(time.Now().Add(-time.Minute), time.Hour).Allow())
+	assert.False(t, NewDeprecationExpirationPolicyFromRotation(time.Now().Add(time.Minute), time.Hour).Allow(),
+		"Should not allow deprecated keys before the rotation")
+	assert.False(t, NewDeprecationExpirationPolicyFromRotation(time.Now().Add(-time.Hour), time.Minute).Allow(),
+		"Should not allow deprecated keys after the grace period")
+}
+
+func TestAuthorize_DeprecationExpirationPolicyFromRotation(t *testing.T) {
+	t.Parallel()
+
+	rotatedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	clock := apikeytest.NewClock(rotatedAt.Add(-time.Second))
+	handler := Authorize(Options{
+		SecretProvider:              testSecretProvider{currentSecret: "current-key", deprecatedSecret: "deprecated-key"},
+		DeprecationExpirationPolicy: NewDeprecationExpirationPolicyFromRotation(rotatedAt, 72*time.Hour),
+		HeaderAuthProvider:          XApiKeyHeader{},
+		Clock:                       clock,
+		FailureHandler:              ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantStatus int
+		wantReason FailureReason
+	}{
+		{name: "before the rotation", now: rotatedAt.Add(-time.Second), wantStatus: http.StatusUnauthorized, wantReason: FailureReasonKeyNotYetValid},
+		{name: "at the rotation", now: rotatedAt, wantStatus: http.StatusNoContent},
+		{name: "end of the grace period", now: rotatedAt.Add(72*time.Hour - time.Second), wantStatus: http.StatusNoContent},
+		{name: "after the grace period", now: rotatedAt.Add(72 * time.Hour), wantStatus: http.StatusUnauthorized, wantReason: FailureReasonKeyExpired},
+	}
+	for _, tt := range tests {
+		clock.Set(tt.now)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderNameXApiKey, "deprecated-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.name)
+		if tt.wantReason != "" {
+			assert.Contains(t, w.Body.String(), `"reason":"`+string(tt.wantReason)+`"`, tt.name)
+		}
+	}
+}
+
+func TestNewDeprecationExpirationPolicyFromRotationEnvironment(t *testing.T) {
+	t.Setenv("API_KEY_ROTATED_AT", "2025-03-01T00:00:00Z")
+	t.Setenv("API_KEY_GRACE_PERIOD", "72h")
+
+	p, err := NewDeprecationExpirationPolicyFromRotationEnvironment("API_KEY_ROTATED_AT", "API_KEY_GRACE_PERIOD")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), p.startAt)
+	assert.Equal(t, time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC), p.expireAt)
+
+	t.Setenv("API_KEY_GRACE_PERIOD", "3 days")
+	_, err = NewDeprecationExpirationPolicyFromRotationEnvironment("API_KEY_ROTATED_AT", "API_KEY_GRACE_PERIOD")
+	require.Error(t, err)
+
+	t.Setenv("API_KEY_GRACE_PERIOD", "72h")
+	t.Setenv("API_KEY_ROTATED_AT", "yesterday")
+	_, err = NewDeprecationExpirationPolicyFromRotationEnvironment("API_KEY_ROTATED_AT", "API_KEY_GRACE_PERIOD")
+	require.Error(t, err)
+}
+
+func TestNewDeprecationExpirationPolicyFromDuration(t *testing.T) {
+	t.Parallel()
+
+	p := NewDeprecationExpirationPolicyFromDuration(time.Hour)
+	assert.Equal(t, processStartTime.Add(time.Hour), p.expireAt, "Should count the grace period from process start")
+	assert.True(t, p.startAt.IsZero())
+
+	assert.True(t, p.Allow())
+	assert.False(t, NewDeprecationExpirationPolicyFromDuration(0).Allow())
+
+	p, err := NewDeprecationExpirationPolicyFromDurationString("90m")
+	require.NoError(t, err)
+	assert.Equal(t, processStartTime.Add(90*time.Minute), p.expireAt)
+
+	for _, s := range []string{"", "90", "-1h"} {
+		_, err := NewDeprecationExpirationPolicyFromDurationString(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestNewDeprecationExpirationPolicyFromDurationEnvironment(t *testing.T) {
+	t.Setenv("API_KEY_GRACE_PERIOD", "24h")
+
+	p, err := NewDeprecationExpirationPolicyFromDurationEnvironment("API_KEY_GRACE_PERIOD")
+	require.NoError(t, err)
+	assert.Equal(t, processStartTime.Add(24*time.Hour), p.expireAt)
+
+	_, err = NewDeprecationExpirationPolicyFromDurationEnvironment("NONEXISTENT_VAR")
+	require.Error(t, err)
+}
+
+func TestNewDeprecationExpirationPolicyFromUnix(t *testing.T) {
+	t.Parallel()
+
+	p := NewDeprecationExpirationPolicyFromUnix(1740787200)
+	assert.True(t, p.expireAt.Equal(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)))
+
+	p, err := NewDeprecationExpirationPolicyFromUnixString("1740787200")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1740787200), p.expireAt.Unix())
+
+	for _, s := range []string{"", "2025-03-01T00:00:00Z", "1740787200.5"} {
+		_, err := NewDeprecationExpirationPolicyFromUnixString(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestNewDeprecationExpirationPolicyFromUnixEnvironment(t *testing.T) {
+	t.Setenv("API_KEY_EXPIRATION_UNIX", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+	p, err := NewDeprecationExpirationPolicyFromUnixEnvironment("API_KEY_EXPIRATION_UNIX")
+	require.NoError(t, err)
+	assert.True(t, p.Allow())
+
+	_, err = NewDeprecationExpirationPolicyFromUnixEnvironment("NONEXISTENT_VAR")
+	require.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// KeyHash verifies request keys against a hashed secret, so that plaintext keys do not need to be stored.
+// Requests without a key ID hint are verified against every key, so each slow hash (bcrypt, argon2id)
+// adds its full cost to every request, including those of unauthenticated clients.
+type KeyHash interface {
+	Verify(secret string) bool
+	String() string
+}
+
+var ErrInvalidKeyHash = errors.New("apikey: invalid key hash")
+
+const saltSize = 16
+
+// SHA256KeyHash is a salted SHA-256 hash, suitable for high-entropy, randomly generated keys.
+// Encoded as sha256$<salt>$<sum>, using unpadded base64 for both parts.
+type SHA256KeyHash struct {
+	Salt []byte
+	Sum  []byte
+}
+
+var _ KeyHash = SHA256KeyHash{}
+
+func NewSHA256KeyHash(secret string) (SHA256KeyHash, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return SHA256KeyHash{}, err
+	}
+	return SHA256KeyHash{Salt: salt, Sum: sha256Sum(salt, secret)}, nil
+}
+
+func (h SHA256KeyHash) Verify(secret string) bool {
+	return len(h.Sum) > 0 && subtle.ConstantTimeCompare(h.Sum, sha256Sum(h.Salt, secret)) == 1
+}
+
+func (h SHA256KeyHash) String() string {
+	return "sha256$" + base64.RawStdEncoding.EncodeToString(h.Salt) + "$" + base64.RawStdEncoding.EncodeToString(h.Sum)
+}
+
+func sha256Sum(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+// MaxBcryptCost caps the cost of parsed bcrypt hashes, which is paid on every verification.
+const MaxBcryptCost = 14
+
+// BcryptKeyHash is a bcrypt hash in its standard encoding ($2a$, $2b$ or $2y$).
+type BcryptKeyHash []byte
+
+var _ KeyHash = BcryptKeyHash(nil)
+
+func NewBcryptKeyHash(secret string, cost int) (BcryptKeyHash, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(secret), cost)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h BcryptKeyHash) Verify(secret string) bool {
+	return bcrypt.CompareHashAndPassword(h, []byte(secret)) == nil
+}
+
+func (h BcryptKeyHash) String() string {
+	return string(h)
+}
+
+// Argon2idKeyHash is an argon2id hash, encoded in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+type Argon2idKeyHash struct {
+	Salt    []byte
+	Key     []byte
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+var _ KeyHash = Argon2idKeyHash{}
+
+// Default argon2id parameters, following the OWASP recommendations.
+const (
+	DefaultArgon2idTime    = 2
+	DefaultArgon2idMemory  = 19 * 1024
+	DefaultArgon2idThreads = 1
+	argon2idKeyLength      = 32
+)
+
+// Upper bounds of the argon2id parameters of parsed hashes, since every verification
+// allocates Memory KiB and runs Time passes over it.
+const (
+	MaxArgon2idTime      = 10
+	MaxArgon2idMemory    = 64 * 1024
+	MaxArgon2idThreads   = 16
+	maxArgon2idKeyLength = 64
+)
+
+func NewArgon2idKeyHash(secret string) (Argon2idKeyHash, error) {
+	salt, err := randomSalt()
+	if err != nil {
+		return Argon2idKeyHash{}, err
+	}
+	h := Argon2idKeyHash{
+		Salt:    salt,
+		Time:    DefaultArgon2idTime,
+		Memory:  DefaultArgon2idMemory,
+		Threads: DefaultArgon2idThreads,
+	}
+	h.Key = h.derive(secret, argon2idKeyLength)
+	return h, nil
+}
+
+func (h Argon2idKeyHash) Verify(secret string) bool {
+	if !h.valid() {
+		return false
+	}
+	return subtle.ConstantTimeCompare(h.Key, h.derive(secret, uint32(len(h.Key)))) == 1 // #nosec G115
+}
+
+// valid reports whether the parameters of the hash are within bounds.
+func (h Argon2idKeyHash) valid() bool {
+	return len(h.Key) > 0 && len(h.Key) <= maxArgon2idKeyLength &&
+		h.Time > 0 && h.Time <= MaxArgon2idTime &&
+		h.Memory <= MaxArgon2idMemory &&
+		h.Threads > 0 && h.Threads <= MaxArgon2idThreads
+}
+
+func (h Argon2idKeyHash) derive(secret string, keyLength uint32) []byte {
+	return argon2.IDKey([]byte(secret), h.Salt, h.Time, h.Memory, h.Threads, keyLength)
+}
+
+func (h Argon2idKeyHash) String() string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(h.Salt),
+		base64.RawStdEncoding.EncodeToString(h.Key))
+}
+
+// ParseKeyHash decodes a hash produced by the String method of any of the supported KeyHash implementations.
+func ParseKeyHash(encoded string) (KeyHash, error) {
+	switch {
+	case strings.HasPrefix(encoded, "sha256$"):
+		return parseSHA256KeyHash(encoded)
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return parseArgon2idKeyHash(encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+		}
+		if cost > MaxBcryptCost {
+			return nil, fmt.Errorf("%w: bcrypt cost %d exceeds %d", ErrInvalidKeyHash, cost, MaxBcryptCost)
+		}
+		return BcryptKeyHash(encoded), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported hash format", ErrInvalidKeyHash)
+	}
+}
+
+func parseSHA256KeyHash(encoded string) (SHA256KeyHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 3 {
+		return SHA256KeyHash{}, fmt.Errorf("%w: malformed sha256 hash", ErrInvalidKeyHash)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return SHA256KeyHash{}, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+	}
+	sum, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return SHA256KeyHash{}, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+	}
+	if len(sum) != sha256.Size {
+		return SHA256KeyHash{}, fmt.Errorf("%w: invalid sha256 digest length", ErrInvalidKeyHash)
+	}
+	return SHA256KeyHash{Salt: salt, Sum: sum}, nil
+}
+
+func parseArgon2idKeyHash(encoded string) (Argon2idKeyHash, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: malformed argon2id hash", ErrInvalidKeyHash)
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: unsupported argon2id version", ErrInvalidKeyHash)
+	}
+	var h Argon2idKeyHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.Memory, &h.Time, &h.Threads); err != nil {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+	}
+	var err error
+	if h.Salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+	}
+	if h.Key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: %w", ErrInvalidKeyHash, err)
+	}
+	if !h.valid() {
+		return Argon2idKeyHash{}, fmt.Errorf("%w: invalid argon2id parameters", ErrInvalidKeyHash)
+	}
+	return h, nil
+}
+
+func randomSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
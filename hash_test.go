@@ -0,0 +1,111 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestKeyHash_VerifyAndParse(t *testing.T) {
+	t.Parallel()
+
+	const secret = "test-api-key-123"
+	tests := []struct {
+		name string
+		hash func() (KeyHash, error)
+	}{
+		{
+			name: "sha256",
+			hash: func() (KeyHash, error) { return NewSHA256KeyHash(secret) },
+		},
+		{
+			name: "bcrypt",
+			hash: func() (KeyHash, error) { return NewBcryptKeyHash(secret, bcrypt.MinCost) },
+		},
+		{
+			name: "argon2id",
+			hash: func() (KeyHash, error) { return NewArgon2idKeyHash(secret) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := tt.hash()
+			require.NoError(t, err)
+			assert.True(t, h.Verify(secret))
+			assert.False(t, h.Verify("wrong-key"))
+			assert.False(t, h.Verify(""))
+			assert.NotContains(t, h.String(), secret)
+
+			parsed, err := ParseKeyHash(h.String())
+			require.NoError(t, err)
+			assert.True(t, parsed.Verify(secret))
+			assert.False(t, parsed.Verify("wrong-key"))
+			assert.Equal(t, h.String(), parsed.String())
+		})
+	}
+}
+
+func TestNewSHA256KeyHash_PerKeySalt(t *testing.T) {
+	t.Parallel()
+
+	first, err := NewSHA256KeyHash("same-key")
+	require.NoError(t, err)
+	second, err := NewSHA256KeyHash("same-key")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.String(), second.String())
+}
+
+func TestParseKeyHash_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, encoded := range []string{
+		"",
+		"plaintext-key",
+		"sha256$",
+		"sha256$c2FsdA$c3Vt",
+		"sha256$!!$!!",
+		"$2a$invalid",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+		"$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4294967295,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=4294967295,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=255$c2FsdA$a2V5",
+		"$2a$15$" + strings.Repeat("a", 53),
+	} {
+		t.Run(encoded, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseKeyHash(encoded)
+			assert.ErrorIs(t, err, ErrInvalidKeyHash)
+		})
+	}
+}
+
+func TestArgon2idKeyHash_Verify_Bounds(t *testing.T) {
+	t.Parallel()
+
+	h, err := NewArgon2idKeyHash("hashed-key")
+	require.NoError(t, err)
+	h.Memory = MaxArgon2idMemory + 1
+	assert.False(t, h.Verify("hashed-key"), "Should not derive keys with parameters beyond the bounds")
+}
+
+func TestKey_Matches(t *testing.T) {
+	t.Parallel()
+
+	h, err := NewSHA256KeyHash("hashed-key")
+	require.NoError(t, err)
+
+	assert.True(t, Key{Secret: "plain-key"}.Matches("plain-key"))
+	assert.False(t, Key{Secret: "plain-key"}.Matches("other-key"))
+	assert.False(t, Key{}.Matches(""))
+	assert.True(t, Key{Hash: h}.Matches("hashed-key"))
+	assert.False(t, Key{Hash: h, Secret: "plain-key"}.Matches("plain-key"), "Should only verify the hash when set")
+}
@@ -0,0 +1,181 @@
+package apikey
+
+import (
+	"crypto/subtle"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Key is a single API key known to a KeySetProvider.
+type Key struct {
+	ID     string
+	Secret string
+	// Hash is verified against request keys instead of Secret, when set.
+	Hash KeyHash
+	// Owner optionally names the client the key was issued to.
+	Owner string
+	// Scope determines the HTTP methods the key is accepted for.
+	Scope PermissionScope
+	// Scopes lists additional, user-defined scopes granted to the key (e.g. "billing:read").
+	Scopes     []PermissionScope
+	Deprecated bool
+	// NotBefore and ExpiresAt define the validity window of the key.
+	// A zero value leaves the respective side of the window open.
+	NotBefore time.Time
+	ExpiresAt time.Time
+	// AllowedNetworks restricts the client addresses the key is accepted from; any address is accepted when empty.
+	AllowedNetworks []netip.Prefix
+}
+
+// ValidAt reports whether t falls within the validity window of the key.
+func (k Key) ValidAt(t time.Time) bool {
+	return !k.notYetValidAt(t) && !k.expiredAt(t)
+}
+
+func (k Key) notYetValidAt(t time.Time) bool {
+	return !k.NotBefore.IsZero() && t.Before(k.NotBefore)
+}
+
+func (k Key) expiredAt(t time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !t.Before(k.ExpiresAt)
+}
+
+// AllowsAddress reports whether the key is accepted from the client address.
+func (k Key) AllowsAddress(address string) bool {
+	if len(k.AllowedNetworks) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range k.AllowedNetworks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the request key matches the secret or hash of the key.
+func (k Key) Matches(requestKey string) bool {
+	if k.Hash != nil {
+		return k.Hash.Verify(requestKey)
+	}
+	return k.Secret != "" && subtle.ConstantTimeCompare([]byte(k.Secret), []byte(requestKey)) == 1
+}
+
+// HasScope reports whether the key has been granted the given scope.
+// The read-write scope implies the readonly scope.
+func (k Key) HasScope(scope PermissionScope) bool {
+	return hasScope(k.Scope, k.Scopes, scope)
+}
+
+func hasScope(primary PermissionScope, additional []PermissionScope, scope PermissionScope) bool {
+	if scope == primary || slices.Contains(additional, scope) {
+		return true
+	}
+	return scope == PermissionScopeReadonly && primary == PermissionScopeReadWrite
+}
+
+func (k Key) hasCredential() bool {
+	return k.Secret != "" || k.Hash != nil
+}
+
+// KeySetProvider returns the full set of keys accepted by an Authorizer.
+type KeySetProvider interface {
+	Keys() []Key
+}
+
+// StaticKeySet is a fixed list of keys.
+type StaticKeySet []Key
+
+var _ KeySetProvider = StaticKeySet(nil)
+
+func (s StaticKeySet) Keys() []Key {
+	return s
+}
+
+// Key identifiers assigned by SecretProviderKeySet to each secret slot.
+const (
+	KeyIDCurrent            = "current"
+	KeyIDDeprecated         = "deprecated"
+	KeyIDReadonly           = "readonly"
+	KeyIDDeprecatedReadonly = "readonly-deprecated"
+)
+
+// SecretProviderKeySet adapts a SecretProvider to the KeySetProvider interface.
+// Deprecated secrets are only included when the deprecation policy defines an expiration time,
+// which becomes the expiration time of the respective keys; the start time of the policy, if any, becomes their NotBefore.
+type SecretProviderKeySet struct {
+	KeySetProvider
+	SecretProvider              SecretProvider
+	DeprecationExpirationPolicy DeprecationExpirationPolicy
+	// ReadonlyDeprecationExpirationPolicy applies to the deprecated readonly secret instead of
+	// DeprecationExpirationPolicy, when set.
+	ReadonlyDeprecationExpirationPolicy *DeprecationExpirationPolicy
+	// Hashed treats the provided secrets as encoded hashes (see ParseKeyHash) instead of plaintext keys.
+	// Secrets that cannot be parsed are skipped.
+	Hashed bool
+}
+
+var _ KeySetProvider = SecretProviderKeySet{}
+
+func NewSecretProviderKeySet(provider SecretProvider, deprecationPolicy DeprecationExpirationPolicy) SecretProviderKeySet {
+	return SecretProviderKeySet{
+		SecretProvider:              provider,
+		DeprecationExpirationPolicy: deprecationPolicy,
+	}
+}
+
+func (s SecretProviderKeySet) readonlyDeprecationExpirationPolicy() DeprecationExpirationPolicy {
+	if s.ReadonlyDeprecationExpirationPolicy != nil {
+		return *s.ReadonlyDeprecationExpirationPolicy
+	}
+	return s.DeprecationExpirationPolicy
+}
+
+func (s SecretProviderKeySet) Keys() []Key {
+	if s.SecretProvider == nil {
+		return nil
+	}
+	var keys []Key
+	add := func(k Key) {
+		if k.Secret == "" {
+			return
+		}
+		if s.Hashed {
+			h, err := ParseKeyHash(k.Secret)
+			if err != nil {
+				return
+			}
+			k.Secret, k.Hash = "", h
+		}
+		keys = append(keys, k)
+	}
+	add(Key{ID: KeyIDCurrent, Secret: s.SecretProvider.GetCurrentSecret(), Scope: PermissionScopeReadWrite})
+	add(Key{ID: KeyIDReadonly, Secret: s.SecretProvider.GetCurrentReadonlySecret(), Scope: PermissionScopeReadonly})
+	if policy := s.DeprecationExpirationPolicy; !policy.expireAt.IsZero() {
+		add(Key{
+			ID:         KeyIDDeprecated,
+			Secret:     s.SecretProvider.GetDeprecatedSecret(),
+			Scope:      PermissionScopeReadWrite,
+			Deprecated: true,
+			NotBefore:  policy.startAt,
+			ExpiresAt:  policy.expireAt,
+		})
+	}
+	if policy := s.readonlyDeprecationExpirationPolicy(); !policy.expireAt.IsZero() {
+		add(Key{
+			ID:         KeyIDDeprecatedReadonly,
+			Secret:     s.SecretProvider.GetDeprecatedReadonlySecret(),
+			Scope:      PermissionScopeReadonly,
+			Deprecated: true,
+			NotBefore:  policy.startAt,
+			ExpiresAt:  policy.expireAt,
+		})
+	}
+	return keys
+}
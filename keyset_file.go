@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+var ErrInvalidKeySet = errors.New("apikey: invalid key set")
+
+// keyFileEntry is the JSON representation of a Key:
+//
+//	{"id": "contractor", "secret": "...", "scope": "readwrite", "not_before": "2025-03-01T00:00:00Z", "expires_at": "2025-06-01T00:00:00Z"}
+//
+// Either secret or hash (see ParseKeyHash) must be set.
+type keyFileEntry struct {
+	ID              string            `json:"id"`
+	Secret          string            `json:"secret,omitempty"`
+	Hash            string            `json:"hash,omitempty"`
+	Owner           string            `json:"owner,omitempty"`
+	Scope           PermissionScope   `json:"scope"`
+	Scopes          []PermissionScope `json:"scopes,omitempty"`
+	Deprecated      bool              `json:"deprecated,omitempty"`
+	NotBefore       time.Time         `json:"not_before"`
+	ExpiresAt       time.Time         `json:"expires_at"`
+	AllowedNetworks []netip.Prefix    `json:"allowed_networks,omitempty"`
+}
+
+// ParseKeySet decodes a JSON array of keys, with optional not_before and expires_at timestamps (RFC 3339),
+// so that keys can be staged in advance or expire on their own.
+func ParseKeySet(data []byte) ([]Key, error) {
+	var entries []keyFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKeySet, err)
+	}
+	keys := make([]Key, 0, len(entries))
+	for i, e := range entries {
+		key := Key{
+			ID:              e.ID,
+			Secret:          e.Secret,
+			Owner:           e.Owner,
+			Scope:           e.Scope,
+			Scopes:          e.Scopes,
+			Deprecated:      e.Deprecated,
+			NotBefore:       e.NotBefore,
+			ExpiresAt:       e.ExpiresAt,
+			AllowedNetworks: e.AllowedNetworks,
+		}
+		if e.Hash != "" {
+			h, err := ParseKeyHash(e.Hash)
+			if err != nil {
+				return nil, fmt.Errorf("%w: key %d: %w", ErrInvalidKeySet, i, err)
+			}
+			key.Hash = h
+		}
+		if !key.hasCredential() {
+			return nil, fmt.Errorf("%w: key %d: missing secret or hash", ErrInvalidKeySet, i)
+		}
+		if key.Scope == "" {
+			return nil, fmt.Errorf("%w: key %d: missing scope", ErrInvalidKeySet, i)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FileKeySet reads keys from a JSON file (see ParseKeySet), reloading it when it changes, like FileSecretProvider.
+// No keys are returned while the file is missing or unreadable. When the file is changed to invalid contents,
+// e.g. with a JSON syntax error, the last valid keys are kept. Failures are reported by Err and ErrorHandler.
+type FileKeySet struct {
+	Path string
+	// ReloadInterval is the minimum time between two checks of the file for changes.
+	// A zero value checks the file on every access.
+	ReloadInterval time.Duration
+	// ErrorHandler, when set, is called with the error of every failed load of the file.
+	// It is called while the key set is locked, so it must not call Keys.
+	ErrorHandler func(err error)
+	mu           sync.Mutex
+	file         fileSecret
+	loaded       bool
+	parsed       string
+	keys         []Key
+	err          error
+}
+
+var _ KeySetProvider = (*FileKeySet)(nil)
+
+func NewFileKeySet(path string) *FileKeySet {
+	return &FileKeySet{
+		Path:           path,
+		ReloadInterval: DefaultFileReloadInterval,
+	}
+}
+
+func (s *FileKeySet) Keys() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.file.refresh(s.Path, time.Now(), s.ReloadInterval) {
+		return s.keys
+	}
+	if s.file.err != nil {
+		s.keys, s.loaded = nil, false
+		s.fail(s.file.err)
+		return nil
+	}
+	if s.loaded && s.file.value == s.parsed {
+		return s.keys
+	}
+	s.parsed, s.loaded = s.file.value, true
+	keys, err := ParseKeySet([]byte(s.file.value))
+	if err != nil {
+		// Keep the last valid keys, so that a typo does not reject every request.
+		s.fail(err)
+		return s.keys
+	}
+	s.keys, s.err = keys, nil
+	return s.keys
+}
+
+// Err returns the error of the last load of the file, or nil when the current keys have been loaded from it.
+func (s *FileKeySet) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func (s *FileKeySet) fail(err error) {
+	s.err = err
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(err)
+	}
+}
@@ -0,0 +1,152 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeySet(t *testing.T) {
+	t.Parallel()
+
+	hash, err := NewSHA256KeyHash("hashed-secret")
+	require.NoError(t, err)
+
+	keys, err := ParseKeySet([]byte(`[
+		{"id": "current", "secret": "current-secret", "scope": "readwrite", "owner": "billing", "scopes": ["billing:read"]},
+		{"id": "staged", "secret": "staged-secret", "scope": "readwrite", "not_before": "2025-03-01T00:00:00Z"},
+		{"id": "contractor", "hash": "` + hash.String() + `", "scope": "readonly", "expires_at": "2025-06-01T00:00:00Z",
+		 "allowed_networks": ["203.0.113.0/24"]}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	assert.Equal(t, Key{
+		ID:     "current",
+		Secret: "current-secret",
+		Owner:  "billing",
+		Scope:  PermissionScopeReadWrite,
+		Scopes: []PermissionScope{"billing:read"},
+	}, keys[0])
+	assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), keys[1].NotBefore)
+	assert.True(t, keys[1].ExpiresAt.IsZero())
+	assert.Empty(t, keys[2].Secret)
+	assert.True(t, keys[2].Matches("hashed-secret"))
+	assert.Equal(t, PermissionScopeReadonly, keys[2].Scope)
+	assert.Equal(t, time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), keys[2].ExpiresAt)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, keys[2].AllowedNetworks)
+
+	for name, data := range map[string]string{
+		"malformed JSON":    `[{"id": "current"`,
+		"missing secret":    `[{"id": "current", "scope": "readwrite"}]`,
+		"missing scope":     `[{"id": "current", "secret": "current-secret"}]`,
+		"invalid hash":      `[{"id": "current", "hash": "md5$abc", "scope": "readwrite"}]`,
+		"invalid timestamp": `[{"id": "current", "secret": "current-secret", "scope": "readwrite", "expires_at": "tomorrow"}]`,
+		"invalid network":   `[{"id": "current", "secret": "current-secret", "scope": "readwrite", "allowed_networks": ["somewhere"]}]`,
+	} {
+		_, err := ParseKeySet([]byte(data))
+		require.ErrorIs(t, err, ErrInvalidKeySet, name)
+	}
+}
+
+func TestFileKeySet_Keys(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keySet := NewFileKeySet(path)
+	keySet.ReloadInterval = 0
+
+	assert.Empty(t, keySet.Keys(), "Should fail closed when the file is missing")
+
+	writeSecretFile(t, path, `[{"id": "current", "secret": "current-secret", "scope": "readwrite"}]`, time.Now().Add(-time.Hour))
+	keys := keySet.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, "current", keys[0].ID)
+
+	require.NoError(t, keySet.Err())
+
+	writeSecretFile(t, path, `[{"id": "current", "secret": "current-secret"`, time.Now())
+	keys = keySet.Keys()
+	require.Len(t, keys, 1, "Should keep the last valid keys when the file is invalid")
+	assert.Equal(t, "current", keys[0].ID)
+	require.ErrorIs(t, keySet.Err(), ErrInvalidKeySet)
+
+	writeSecretFile(t, path, `[{"id": "next", "secret": "next-secret", "scope": "readwrite"}]`, time.Now().Add(time.Hour))
+	keys = keySet.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, "next", keys[0].ID)
+	require.NoError(t, keySet.Err())
+}
+
+func TestFileKeySet_ErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	var errs []error
+	keySet := NewFileKeySet(path)
+	keySet.ReloadInterval = 0
+	keySet.ErrorHandler = func(err error) {
+		errs = append(errs, err)
+	}
+
+	assert.Empty(t, keySet.Keys(), "Should fail closed when the file is missing")
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], os.ErrNotExist)
+	require.ErrorIs(t, keySet.Err(), os.ErrNotExist)
+
+	writeSecretFile(t, path, `{"id": "current"}`, time.Now())
+	assert.Empty(t, keySet.Keys())
+	require.Len(t, errs, 2)
+	require.ErrorIs(t, errs[1], ErrInvalidKeySet)
+
+	keySet.Keys()
+	assert.Len(t, errs, 2, "Should report a failed load once, until the file changes")
+}
+
+func TestAuthorize_FileKeySet_ValidityWindows(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeSecretFile(t, path, `[
+		{"id": "current", "secret": "current-secret", "scope": "readwrite"},
+		{"id": "staged", "secret": "staged-secret", "scope": "readwrite", "not_before": "`+now.Add(time.Hour).Format(time.RFC3339)+`"},
+		{"id": "contractor", "secret": "contractor-secret", "scope": "readwrite", "expires_at": "`+now.Add(-time.Minute).Format(time.RFC3339)+`"}
+	]`, time.Now().Add(-time.Hour))
+
+	handler := Authorize(Options{
+		KeySetProvider:     NewFileKeySet(path),
+		HeaderAuthProvider: XApiKeyHeader{},
+		FailureHandler:     ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		secret     string
+		wantStatus int
+		wantReason FailureReason
+	}{
+		{secret: "current-secret", wantStatus: http.StatusNoContent},
+		{secret: "staged-secret", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonKeyNotYetValid},
+		{secret: "contractor-secret", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonKeyExpired},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderNameXApiKey, tt.secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.secret)
+		if tt.wantReason != "" {
+			assert.Contains(t, w.Body.String(), `"reason":"`+string(tt.wantReason)+`"`, tt.secret)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package apikey
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey_ValidAt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name string
+		key  Key
+		want bool
+	}{
+		{
+			name: "open validity window",
+			key:  Key{},
+			want: true,
+		},
+		{
+			name: "not yet valid",
+			key:  Key{NotBefore: now.Add(time.Minute)},
+			want: false,
+		},
+		{
+			name: "expired",
+			key:  Key{ExpiresAt: now.Add(-time.Minute)},
+			want: false,
+		},
+		{
+			name: "within validity window",
+			key:  Key{NotBefore: now.Add(-time.Minute), ExpiresAt: now.Add(time.Minute)},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.key.ValidAt(now))
+		})
+	}
+}
+
+func TestKey_AllowsAddress(t *testing.T) {
+	t.Parallel()
+
+	key := Key{AllowedNetworks: []netip.Prefix{
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}}
+
+	assert.True(t, key.AllowsAddress("203.0.113.7"))
+	assert.True(t, key.AllowsAddress("::ffff:203.0.113.7"), "Should unmap IPv4-mapped addresses")
+	assert.True(t, key.AllowsAddress("2001:db8::1"))
+	assert.False(t, key.AllowsAddress("198.51.100.1"))
+	assert.False(t, key.AllowsAddress("not-an-address"))
+	assert.False(t, key.AllowsAddress(""))
+
+	assert.True(t, Key{}.AllowsAddress("198.51.100.1"), "Should allow any address without networks")
+	assert.True(t, Key{}.AllowsAddress(""))
+}
+
+func TestSecretProviderKeySet_Keys(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	expireAt := policy.expireAt
+
+	provider := &testSecretProvider{
+		currentSecret:            "current",
+		deprecatedSecret:         "deprecated",
+		currentReadonlySecret:    "readonly",
+		deprecatedReadonlySecret: "readonly-deprecated",
+	}
+
+	assert.Equal(t, []Key{
+		{ID: KeyIDCurrent, Secret: "current", Scope: PermissionScopeReadWrite},
+		{ID: KeyIDReadonly, Secret: "readonly", Scope: PermissionScopeReadonly},
+		{ID: KeyIDDeprecated, Secret: "deprecated", Scope: PermissionScopeReadWrite, Deprecated: true, ExpiresAt: expireAt},
+		{ID: KeyIDDeprecatedReadonly, Secret: "readonly-deprecated", Scope: PermissionScopeReadonly, Deprecated: true, ExpiresAt: expireAt},
+	}, NewSecretProviderKeySet(provider, policy).Keys())
+
+	assert.Equal(t, []Key{
+		{ID: KeyIDCurrent, Secret: "current", Scope: PermissionScopeReadWrite},
+		{ID: KeyIDReadonly, Secret: "readonly", Scope: PermissionScopeReadonly},
+	}, NewSecretProviderKeySet(provider, DeprecationExpirationPolicy{}).Keys(),
+		"Should skip deprecated secrets without a deprecation policy")
+
+	assert.Empty(t, NewSecretProviderKeySet(&testSecretProvider{}, policy).Keys(), "Should skip empty secrets")
+	assert.Empty(t, NewSecretProviderKeySet(nil, policy).Keys())
+}
+
+func TestSecretProviderKeySet_Keys_Rotation(t *testing.T) {
+	t.Parallel()
+
+	rotatedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	policy := NewDeprecationExpirationPolicyFromRotation(rotatedAt, 72*time.Hour)
+	keys := NewSecretProviderKeySet(&testSecretProvider{deprecatedSecret: "deprecated"}, policy).Keys()
+
+	assert.Equal(t, []Key{{
+		ID:         KeyIDDeprecated,
+		Secret:     "deprecated",
+		Scope:      PermissionScopeReadWrite,
+		Deprecated: true,
+		NotBefore:  rotatedAt,
+		ExpiresAt:  rotatedAt.Add(72 * time.Hour),
+	}}, keys, "Should only accept deprecated keys from the rotation time")
+}
+
+func TestSecretProviderKeySet_Keys_ReadonlyDeprecationPolicy(t *testing.T) {
+	t.Parallel()
+
+	rotatedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	provider := &testSecretProvider{deprecatedSecret: "deprecated", deprecatedReadonlySecret: "readonly-deprecated"}
+	keySet := NewSecretProviderKeySet(provider, NewDeprecationExpirationPolicyFromRotation(rotatedAt, 24*time.Hour))
+	readonlyPolicy := NewDeprecationExpirationPolicyFromRotation(rotatedAt.Add(time.Hour), 30*24*time.Hour)
+	keySet.ReadonlyDeprecationExpirationPolicy = &readonlyPolicy
+
+	assert.Equal(t, []Key{
+		{
+			ID: KeyIDDeprecated, Secret: "deprecated", Scope: PermissionScopeReadWrite, Deprecated: true,
+			NotBefore: rotatedAt, ExpiresAt: rotatedAt.Add(24 * time.Hour),
+		},
+		{
+			ID: KeyIDDeprecatedReadonly, Secret: "readonly-deprecated", Scope: PermissionScopeReadonly, Deprecated: true,
+			NotBefore: rotatedAt.Add(time.Hour), ExpiresAt: rotatedAt.Add(time.Hour + 30*24*time.Hour),
+		},
+	}, keySet.Keys())
+
+	keySet.ReadonlyDeprecationExpirationPolicy = &DeprecationExpirationPolicy{}
+	keys := keySet.Keys()
+	require.Len(t, keys, 1, "Should skip the deprecated readonly secret without a readonly deprecation policy")
+	assert.Equal(t, KeyIDDeprecated, keys[0].ID)
+
+	keySet.DeprecationExpirationPolicy = DeprecationExpirationPolicy{}
+	keySet.ReadonlyDeprecationExpirationPolicy = &readonlyPolicy
+	keys = keySet.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, KeyIDDeprecatedReadonly, keys[0].ID)
+}
+
+func TestSecretProviderKeySet_Hashed(t *testing.T) {
+	t.Parallel()
+
+	h, err := NewSHA256KeyHash("current")
+	require.NoError(t, err)
+
+	keySet := NewSecretProviderKeySet(&testSecretProvider{
+		currentSecret:         h.String(),
+		currentReadonlySecret: "not-a-hash",
+	}, DeprecationExpirationPolicy{})
+	keySet.Hashed = true
+
+	keys := keySet.Keys()
+	require.Len(t, keys, 1, "Should skip secrets that are not valid hashes")
+	assert.Equal(t, KeyIDCurrent, keys[0].ID)
+	assert.Empty(t, keys[0].Secret)
+	assert.True(t, keys[0].Matches("current"))
+}
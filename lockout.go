@@ -0,0 +1,162 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLockoutMaxFailures   = 5
+	DefaultLockoutDuration      = time.Minute
+	DefaultLockoutMaxDuration   = time.Hour
+	DefaultLockoutSweepInterval = time.Minute
+)
+
+// lockoutPendingRetryAfter is the Retry-After duration of clients rejected while their earlier attempts,
+// enough to lock them out, are still being authorized.
+const lockoutPendingRetryAfter = time.Second
+
+var ErrLockedOut = errors.New("apikey: too many failed attempts")
+
+// LockoutOptions configures the lockout of clients after repeated authentication failures.
+// Failures are counted per client IP address, as determined by Options.ClientIPStrategy. Once MaxFailures is reached, the client is locked out
+// for Duration, doubling with every further failure up to MaxDuration. Requests of locked out clients
+// are rejected with 429 Too Many Requests, before their key is compared against any secret.
+// Failures older than MaxDuration are forgotten, as are all failures of a client once it is authenticated.
+type LockoutOptions struct {
+	// MaxFailures is the number of failures before a client is locked out; defaults to DefaultLockoutMaxFailures.
+	MaxFailures int
+	// Duration is the initial lockout duration; defaults to DefaultLockoutDuration.
+	Duration time.Duration
+	// MaxDuration caps the lockout duration; defaults to DefaultLockoutMaxDuration.
+	MaxDuration time.Duration
+	// FailureHandler responds to locked out clients; defaults to DefaultRateLimitedHandler.
+	FailureHandler http.HandlerFunc
+}
+
+// lockoutTracker keeps the failures of each client in memory. A nil tracker never locks clients out.
+type lockoutTracker struct {
+	options LockoutOptions
+
+	mu        sync.Mutex
+	clients   map[string]*lockoutState
+	nextSweep time.Time
+}
+
+type lockoutState struct {
+	failures    int
+	pending     int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newLockoutTracker(options LockoutOptions) *lockoutTracker {
+	if options.MaxFailures <= 0 {
+		options.MaxFailures = DefaultLockoutMaxFailures
+	}
+	if options.Duration <= 0 {
+		options.Duration = DefaultLockoutDuration
+	}
+	if options.MaxDuration <= 0 {
+		options.MaxDuration = DefaultLockoutMaxDuration
+	}
+	if options.FailureHandler == nil {
+		options.FailureHandler = DefaultRateLimitedHandler()
+	}
+	return &lockoutTracker{options: options, clients: make(map[string]*lockoutState)}
+}
+
+// acquire reserves an authentication attempt of the client, returning zero, or returns how long the client has
+// to wait before trying again. Once a client has failed, its concurrent attempts are reserved against the failures
+// left before a lockout, so that a burst of guesses cannot all pass the check before they are recorded.
+// Clients without failures are never limited. Every reserved attempt must be released.
+func (l *lockoutTracker) acquire(client string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.nextSweep) {
+		l.sweep(now)
+	}
+	s, ok := l.clients[client]
+	if !ok {
+		s = &lockoutState{}
+		l.clients[client] = s
+	}
+	if now.Before(s.lockedUntil) {
+		return s.lockedUntil.Sub(now)
+	}
+	l.forgetStaleFailures(s, now)
+	// Once a lockout has expired, the client is allowed one attempt at a time.
+	if s.failures > 0 && s.pending >= max(l.options.MaxFailures-s.failures, 1) {
+		return lockoutPendingRetryAfter
+	}
+	s.pending++
+	return 0
+}
+
+// release settles an attempt reserved by acquire, counting the decision towards the lockout of the client.
+// Only invalid keys and signatures count as failures, since other failures do not reveal whether a guess was right.
+func (l *lockoutTracker) release(client string, decision Decision, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.clients[client]
+	if !ok {
+		s = &lockoutState{}
+		l.clients[client] = s
+	}
+	s.pending = max(s.pending-1, 0)
+	if decision.Outcome == OutcomeAuthenticated {
+		s.failures = 0
+		s.lockedUntil = time.Time{}
+		if s.pending == 0 {
+			delete(l.clients, client)
+		}
+		return
+	}
+	if decision.Reason != FailureReasonInvalidKey && decision.Reason != FailureReasonInvalidSignature {
+		return
+	}
+	l.forgetStaleFailures(s, now)
+	s.failures++
+	s.lastFailure = now
+	if s.failures >= l.options.MaxFailures {
+		s.lockedUntil = now.Add(l.lockoutDuration(s.failures - l.options.MaxFailures))
+	}
+}
+
+// forgetStaleFailures resets the failures of the client when the last one is older than MaxDuration.
+func (l *lockoutTracker) forgetStaleFailures(s *lockoutState, now time.Time) {
+	if now.Sub(s.lastFailure) > l.options.MaxDuration {
+		s.failures = 0
+	}
+}
+
+// lockoutDuration doubles the lockout duration for every failure beyond the maximum, up to MaxDuration.
+func (l *lockoutTracker) lockoutDuration(excessFailures int) time.Duration {
+	d := l.options.Duration
+	for range excessFailures {
+		if d >= l.options.MaxDuration/2 {
+			return l.options.MaxDuration
+		}
+		d *= 2
+	}
+	return min(d, l.options.MaxDuration)
+}
+
+func (l *lockoutTracker) sweep(now time.Time) {
+	for client, s := range l.clients {
+		if s.pending == 0 && !now.Before(s.lockedUntil) && now.Sub(s.lastFailure) > l.options.MaxDuration {
+			delete(l.clients, client)
+		}
+	}
+	l.nextSweep = now.Add(DefaultLockoutSweepInterval)
+}
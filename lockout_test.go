@@ -0,0 +1,286 @@
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockoutTracker(t *testing.T) {
+	t.Parallel()
+
+	l := newLockoutTracker(LockoutOptions{MaxFailures: 3, Duration: time.Second, MaxDuration: 5 * time.Second})
+	now := time.Unix(1740787200, 0)
+	invalid := newFailureDecision(Key{}, ErrInvalidKey)
+	attempt := func(client string, decision Decision, now time.Time) {
+		t.Helper()
+		require.Zero(t, l.acquire(client, now))
+		l.release(client, decision, now)
+	}
+
+	attempt("a", newFailureDecision(Key{}, ErrMissingCredential), now)
+	attempt("a", invalid, now)
+	attempt("a", invalid, now)
+	attempt("a", invalid, now)
+	assert.Equal(t, time.Second, l.acquire("a", now))
+	assert.Zero(t, l.acquire("b", now), "Should count failures per client")
+	l.release("b", Decision{Outcome: OutcomeAuthenticated}, now)
+
+	for _, want := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		now = now.Add(5 * time.Second)
+		attempt("a", invalid, now)
+		assert.Equal(t, want, l.acquire("a", now), "Should back off exponentially")
+	}
+
+	now = now.Add(5 * time.Second)
+	attempt("a", Decision{Outcome: OutcomeAuthenticated}, now)
+	assert.Zero(t, l.acquire("a", now), "Should forget failures once authenticated")
+	l.release("a", invalid, now)
+
+	for range 2 {
+		attempt("c", invalid, now)
+	}
+	attempt("c", invalid, now.Add(time.Minute))
+	assert.Zero(t, l.acquire("c", now.Add(time.Minute)), "Should forget failures older than the maximum duration")
+
+	var disabled *lockoutTracker
+	assert.Zero(t, disabled.acquire("a", now))
+	disabled.release("a", invalid, now)
+}
+
+func TestLockoutTracker_ConcurrentAttempts(t *testing.T) {
+	t.Parallel()
+
+	l := newLockoutTracker(LockoutOptions{MaxFailures: 3})
+	now := time.Unix(1740787200, 0)
+	invalid := newFailureDecision(Key{}, ErrInvalidKey)
+
+	for range 2 * l.options.MaxFailures {
+		require.Zero(t, l.acquire("a", now), "Should not limit concurrent attempts of clients without failures")
+	}
+	for range 2 * l.options.MaxFailures {
+		l.release("a", Decision{Outcome: OutcomeAuthenticated}, now)
+	}
+
+	require.Zero(t, l.acquire("a", now))
+	l.release("a", invalid, now)
+	require.Zero(t, l.acquire("a", now))
+	require.Zero(t, l.acquire("a", now))
+	assert.Equal(t, lockoutPendingRetryAfter, l.acquire("a", now),
+		"Should reject attempts beyond the failures left while the others are pending")
+
+	l.release("a", newFailureDecision(Key{}, ErrMissingCredential), now)
+	require.Zero(t, l.acquire("a", now), "Should release attempts that are not counted as failures")
+	l.release("a", invalid, now)
+	l.release("a", invalid, now)
+	assert.Equal(t, DefaultLockoutDuration, l.acquire("a", now))
+
+	now = now.Add(DefaultLockoutDuration)
+	require.Zero(t, l.acquire("a", now))
+	assert.Equal(t, lockoutPendingRetryAfter, l.acquire("a", now),
+		"Should allow one attempt at a time once a lockout has expired")
+}
+
+func TestAuthorize_Lockout_ClientIPStrategy(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		KeySetProvider:     StaticKeySet{{ID: "current", Secret: "valid-secret", Scope: PermissionScopeReadWrite}},
+		HeaderAuthProvider: XApiKeyHeader{},
+		ClientIPStrategy:   TrustedProxyClientIP{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		Lockout:            &LockoutOptions{MaxFailures: 1},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(forwardedFor, secret string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.2:1"
+		r.Header.Set(HeaderNameXForwardedFor, forwardedFor)
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("203.0.113.7", "guess"))
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7", "valid-secret"))
+	assert.Equal(t, http.StatusNoContent, send("198.51.100.1", "valid-secret"),
+		"Should not lock out other clients behind the same proxy")
+}
+
+func TestAuthorize_Lockout_ConcurrentGuesses(t *testing.T) {
+	t.Parallel()
+
+	const maxFailures = 4
+	keySet := newBlockingKeySet(StaticKeySet{{ID: "current", Secret: "valid-secret"}})
+	handler := Authorize(Options{
+		KeySetProvider:     keySet,
+		HeaderAuthProvider: XApiKeyHeader{},
+		Lockout:            &LockoutOptions{MaxFailures: maxFailures},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(secret string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "203.0.113.7:1"
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, send("guess"))
+	codes := sendConcurrently(keySet, 2*maxFailures, maxFailures-1, func(i int) int {
+		return send(fmt.Sprintf("guess-%d", i))
+	})
+	assert.Equal(t, map[int]int{http.StatusUnauthorized: maxFailures - 1, http.StatusTooManyRequests: maxFailures + 1}, codes,
+		"Should only let the failures left before a lockout through")
+	assert.Equal(t, http.StatusTooManyRequests, send("valid-secret"))
+}
+
+func TestAuthorize_Lockout_ConcurrentValidRequests(t *testing.T) {
+	t.Parallel()
+
+	const requests = 4 * DefaultLockoutMaxFailures
+	keySet := newBlockingKeySet(StaticKeySet{{ID: "current", Secret: "valid-secret"}})
+	handler := Authorize(Options{
+		KeySetProvider:     keySet,
+		HeaderAuthProvider: XApiKeyHeader{},
+		Lockout:            &LockoutOptions{},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	codes := sendConcurrently(keySet, requests, requests, func(int) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "203.0.113.7:1"
+		r.Header.Set(HeaderNameXApiKey, "valid-secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	})
+	assert.Equal(t, map[int]int{http.StatusNoContent: requests}, codes,
+		"Should not limit concurrent requests of clients without failures")
+}
+
+// sendConcurrently sends the requests concurrently, holding the given number of them in the key set
+// until all have arrived, and counts the response status codes.
+func sendConcurrently(keySet *blockingKeySet, requests, held int, send func(i int) int) map[int]int {
+	keySet.hold(held)
+	codes := make(chan int, requests)
+	var done sync.WaitGroup
+	for i := range requests {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			codes <- send(i)
+		}()
+	}
+	keySet.arrived.Wait()
+	close(keySet.release)
+	done.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	return counts
+}
+
+// blockingKeySet holds authentication attempts until released, to keep them in flight concurrently.
+type blockingKeySet struct {
+	keys    StaticKeySet
+	arrived sync.WaitGroup
+	release chan struct{}
+}
+
+func newBlockingKeySet(keys StaticKeySet) *blockingKeySet {
+	release := make(chan struct{})
+	close(release)
+	return &blockingKeySet{keys: keys, release: release}
+}
+
+// hold blocks the next attempts until the given number of them has arrived.
+func (b *blockingKeySet) hold(attempts int) {
+	b.arrived.Add(attempts)
+	b.release = make(chan struct{})
+}
+
+func (b *blockingKeySet) Keys() []Key {
+	select {
+	case <-b.release:
+	default:
+		b.arrived.Done()
+		<-b.release
+	}
+	return b.keys.Keys()
+}
+
+func TestAuthorize_Lockout_StripFromURL(t *testing.T) {
+	t.Parallel()
+
+	var observed, handled []string
+	handler := Authorize(Options{
+		KeySetProvider:     StaticKeySet{{ID: "current", Secret: "valid-secret", Scope: PermissionScopeReadWrite}},
+		HeaderAuthProvider: QueryParamAuth{StripFromURL: true},
+		Observer: ObserverFunc(func(r *http.Request, _ Event) {
+			observed = append(observed, r.URL.String())
+		}),
+		Lockout: &LockoutOptions{
+			MaxFailures: 1,
+			FailureHandler: func(w http.ResponseWriter, r *http.Request) {
+				handled = append(handled, r.URL.String())
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, guess := range []string{"guess-1", "guess-2"} {
+		r := httptest.NewRequest(http.MethodGet, "/hook?api_key="+guess+"&event=push", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	assert.Equal(t, []string{"/hook?event=push", "/hook?event=push"}, observed)
+	assert.Equal(t, []string{"/hook?event=push"}, handled, "Should strip the credential of locked out requests")
+}
+
+func TestAuthorize_Lockout(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		KeySetProvider:     StaticKeySet{{ID: "current", Secret: "valid-secret", Scope: PermissionScopeReadWrite}},
+		HeaderAuthProvider: XApiKeyHeader{},
+		Lockout:            &LockoutOptions{MaxFailures: 2, FailureHandler: ProblemDetailsHandler()},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	send := func(remoteAddr, secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("203.0.113.7:1", "guess-1").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("203.0.113.7:2", "guess-2").Code)
+
+	w := send("203.0.113.7:3", "valid-secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "Should reject locked out clients even with a valid key")
+	assert.Equal(t, "60", w.Header().Get(HeaderNameRetryAfter))
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+	assert.Contains(t, w.Body.String(), `"reason":"locked_out"`)
+
+	assert.Equal(t, http.StatusNoContent, send("198.51.100.1:1", "valid-secret").Code, "Should not lock out other clients")
+}
@@ -1,18 +1,57 @@
 package apikey
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Options struct {
-	ReadOnly                    bool
-	FailureHandler              http.HandlerFunc
+	ReadOnly       bool
+	FailureHandler http.HandlerFunc
+	// ForbiddenHandler is called for requests with a valid key that is not permitted for the request.
+	// Defaults to FailureHandler when that is set, otherwise to DefaultForbiddenHandler.
+	ForbiddenHandler            http.HandlerFunc
 	SecretProvider              SecretProvider
 	DeprecationExpirationPolicy DeprecationExpirationPolicy
-	HeaderAuthProvider          HeaderAuthProvider
+	// ReadonlyDeprecationExpirationPolicy applies to the deprecated readonly secret instead of
+	// DeprecationExpirationPolicy, when set, so that readonly keys can be rotated on their own schedule.
+	ReadonlyDeprecationExpirationPolicy *DeprecationExpirationPolicy
+	// KeySetProvider replaces SecretProvider and the deprecation policies when set.
+	KeySetProvider KeySetProvider
+	// HashedSecrets treats the values returned by SecretProvider as encoded key hashes (see ParseKeyHash).
+	HashedSecrets      bool
+	HeaderAuthProvider HeaderAuthProvider
 	// AllowedHTTPMethodsOverride allows customization of accepted HTTP methods.
 	// A common use case is POST requests that actually perform read operations.
 	AllowedHTTPMethodsOverride []string
+	// RequiredScopes must all be granted to the key of a request, in addition to its method-based scope.
+	RequiredScopes []PermissionScope
+	// RoutePolicy decides the accepted key scopes per chi route pattern and HTTP method,
+	// replacing the method-based check for matching routes.
+	RoutePolicy RoutePolicy
+	// Observer is notified of every authorization decision, e.g. for collecting metrics.
+	Observer Observer
+	// AuditLogger logs every authorization decision with the HTTP method, route pattern, remote address,
+	// key ID, scope and failure reason. Secrets are never logged.
+	AuditLogger *slog.Logger
+	// TracerProvider creates the span wrapping the authorization of each request.
+	// Defaults to the global OpenTelemetry tracer provider, which is a no-op unless configured.
+	TracerProvider trace.TracerProvider
+	// RequestSigning enables HMAC request signing: requests are authorized by verifying their signature
+	// against the keys, instead of reading the key with HeaderAuthProvider.
+	RequestSigning *RequestSigningOptions
+	// ClientIPStrategy determines the client address checked against the allowed networks of keys
+	// and counted towards lockouts; defaults to RemoteAddrClientIP.
+	ClientIPStrategy ClientIPStrategy
+	// Clock provides the current time for validity windows, signature timestamps and lockouts;
+	// defaults to the system clock.
+	Clock Clock
+	// Lockout enables the temporary lockout of clients after repeated authentication failures.
+	Lockout *LockoutOptions
 }
 
 func NewOptions() Options {
@@ -43,6 +82,12 @@ func NewReadonlyOptions() Options {
 
 // Authorize implements a simple middleware handler for creating header-based authentication schemes.
 func Authorize(options Options) func(next http.Handler) http.Handler {
+	if options.ForbiddenHandler == nil {
+		options.ForbiddenHandler = options.FailureHandler
+		if options.ForbiddenHandler == nil {
+			options.ForbiddenHandler = DefaultForbiddenHandler()
+		}
+	}
 	if options.FailureHandler == nil {
 		options.FailureHandler = DefaultUnauthorizedHandler()
 	}
@@ -56,19 +101,115 @@ func Authorize(options Options) func(next http.Handler) http.Handler {
 		scope,
 		options.AllowedHTTPMethodsOverride,
 	)
+	auth.ReadonlyDeprecationExpirationPolicy = options.ReadonlyDeprecationExpirationPolicy
+	auth.KeySetProvider = options.KeySetProvider
+	auth.RequiredScopes = options.RequiredScopes
+	auth.RoutePolicy = options.RoutePolicy
+	auth.ClientIPStrategy = options.ClientIPStrategy
+	auth.Clock = options.Clock
+	if auth.KeySetProvider == nil && options.HashedSecrets {
+		keySet := auth.secretProviderKeySet()
+		keySet.Hashed = true
+		auth.KeySetProvider = keySet
+	}
+	if options.TracerProvider == nil {
+		options.TracerProvider = otel.GetTracerProvider()
+	}
+	tracer := options.TracerProvider.Tracer(tracerName)
+	var observers multiObserver
+	if options.Observer != nil {
+		observers = append(observers, options.Observer)
+	}
+	if options.AuditLogger != nil {
+		observers = append(observers, auditObserver{logger: options.AuditLogger})
+	}
+	var lockout *lockoutTracker
+	if options.Lockout != nil {
+		lockout = newLockoutTracker(*options.Lockout)
+	}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestKey, ok := options.HeaderAuthProvider.Secret(r)
-			if !ok {
-				options.FailureHandler(w, r)
-				return
+			start := time.Now()
+			_, span := tracer.Start(r.Context(), spanNameAuthorize)
+			var client string
+			if lockout != nil {
+				client = auth.clientIP(r)
 			}
-			if !auth.IsValidRequest(r, requestKey) {
-				options.FailureHandler(w, r.WithContext(NewUnauthorizedContext(r.Context())))
-				return
+			var decision Decision
+			now := clockNow(options.Clock)
+			retryAfter := lockout.acquire(client, now)
+			if retryAfter > 0 {
+				decision = newFailureDecision(Key{}, ErrLockedOut)
+				r = stripCredential(options.HeaderAuthProvider, r)
+			} else {
+				decision, r = decide(auth, options, r, now)
+				lockout.release(client, decision, now)
+			}
+			recordDecision(span, decision)
+			span.End()
+			if len(observers) > 0 {
+				observers.ObserveAuthorization(r, Event{Decision: decision, Duration: time.Since(start)})
 			}
 
-			next.ServeHTTP(w, r)
+			switch decision.Outcome {
+			case OutcomeAuthenticated:
+				setDeprecationHeaders(w, decision.Key, now)
+				next.ServeHTTP(w, r.WithContext(NewPrincipalContext(r.Context(), NewPrincipal(decision.Key))))
+			case OutcomeForbidden:
+				setAuthenticateHeader(w, options.HeaderAuthProvider, decision.Reason)
+				options.ForbiddenHandler(w, r.WithContext(newRejectionContext(r.Context(), decision.Reason)))
+			case OutcomeUnauthenticated:
+				if retryAfter > 0 {
+					w.Header().Set(HeaderNameRetryAfter, formatSeconds(retryAfter))
+					lockout.options.FailureHandler(w, r.WithContext(newRejectionContext(r.Context(), decision.Reason)))
+					return
+				}
+				setAuthenticateHeader(w, options.HeaderAuthProvider, decision.Reason)
+				options.FailureHandler(w, r.WithContext(newRejectionContext(r.Context(), decision.Reason)))
+			}
 		})
 	}
 }
+
+// decide authorizes the request, returning the request to pass on to the next handlers.
+func decide(auth Authorizer, options Options, r *http.Request, now time.Time) (Decision, *http.Request) {
+	if options.RequestSigning != nil {
+		signature, r, err := options.RequestSigning.ExtractSignature(r, now)
+		if err != nil {
+			return newFailureDecision(Key{}, err), r
+		}
+		decision := auth.DecideSignature(r, signature)
+		if decision.Outcome == OutcomeAuthenticated && !options.RequestSigning.remember(signature, now) {
+			return newFailureDecision(decision.Key, ErrReplayedSignature), r
+		}
+		return decision, r
+	}
+	credential, err := extractCredential(options.HeaderAuthProvider, r)
+	r = stripCredential(options.HeaderAuthProvider, r)
+	if err != nil {
+		return newFailureDecision(Key{}, err), r
+	}
+	return auth.DecideCredential(r, credential), r
+}
+
+// setAuthenticateHeader sets the WWW-Authenticate header with the challenge of the authentication scheme of the provider.
+func setAuthenticateHeader(w http.ResponseWriter, p HeaderAuthProvider, reason FailureReason) {
+	w.Header().Set("WWW-Authenticate", challenge(p, reason))
+}
+
+// bearerChallenge returns the challenge of the Bearer token scheme of RFC 6750.
+func bearerChallenge(reason FailureReason) string {
+	challenge := "Bearer"
+	switch reason {
+	case FailureReasonMissingCredential, FailureReasonRateLimited, FailureReasonQuotaExceeded, FailureReasonLockedOut:
+		// No error code: either no credential was presented, or the failure is not about the credential.
+	case FailureReasonMalformedCredential, FailureReasonAmbiguousCredential, FailureReasonRequestTooLarge:
+		challenge += ` error="invalid_request"`
+	case FailureReasonInsufficientScope:
+		challenge += ` error="insufficient_scope"`
+	case FailureReasonInvalidKey, FailureReasonKeyExpired, FailureReasonKeyNotYetValid, FailureReasonAddressNotAllowed,
+		FailureReasonInvalidSignature, FailureReasonStaleSignature, FailureReasonReplayedSignature:
+		challenge += ` error="invalid_token"`
+	}
+	return challenge
+}
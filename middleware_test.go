@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -84,6 +85,7 @@ func TestAPITokenAuth_Deny(t *testing.T) {
 	})
 
 	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
 	data, err := io.ReadAll(resp.Body)
 	require.NoError(t, err)
 
@@ -119,7 +121,8 @@ func TestAPITokenAuth_Deny_Readonly_MethodNotSupported(t *testing.T) {
 		_ = resp.Body.Close()
 	})
 
-	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, `Bearer error="insufficient_scope"`, resp.Header.Get("WWW-Authenticate"))
 	data, err := io.ReadAll(resp.Body)
 	require.NoError(t, err)
 
@@ -210,6 +213,46 @@ func TestAPITokenAuth_Deny_NoSecretFoundInRequest(t *testing.T) {
 	assert.Equal(t, []byte("something went wrong in TestAPITokenAuth_Deny_NoSecretFoundInRequest"), data, string(data))
 }
 
+func TestAuthorize_IsUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider:     StaticKeySet{{ID: "current", Secret: "valid-secret", Scope: PermissionScopeReadWrite}},
+		FailureHandler: func(w http.ResponseWriter, r *http.Request) {
+			if IsUnauthorized(r.Context()) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		requestKey string
+		wantStatus int
+	}{
+		{name: "rejected credential", requestKey: "wrong-secret", wantStatus: http.StatusUnauthorized},
+		{name: "missing credential", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.requestKey != "" {
+				r.Header.Set(HeaderNameXApiKey, tt.requestKey)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
 func TestNewOptions(t *testing.T) {
 	t.Parallel()
 
@@ -249,3 +292,243 @@ func apiKeySecret(t *testing.T) string {
 	t.Helper()
 	return "test-" + strings.Repeat("t", 20)
 }
+
+func TestAPITokenAuth_Principal(t *testing.T) {
+	router := chi.NewRouter()
+
+	router.Use(
+		Authorize(Options{
+			HeaderAuthProvider: XApiKeyHeader{},
+			KeySetProvider: StaticKeySet{
+				{ID: "reporting", Owner: "reporting-team", Secret: apiKeySecret(t), Scope: PermissionScopeReadWrite},
+			},
+		}))
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		assert.True(t, ok)
+		_, err := w.Write([]byte(p.KeyID + "/" + p.Owner + "/" + string(p.Scope)))
+		require.NoError(t, err)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set(HeaderNameXApiKey, apiKeySecret(t))
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "reporting/reporting-team/readwrite", string(data))
+}
+
+func TestAPITokenAuth_HashedSecrets(t *testing.T) {
+	h, err := NewSHA256KeyHash(apiKeySecret(t))
+	require.NoError(t, err)
+	t.Setenv(envVariableName, h.String())
+
+	router := chi.NewRouter()
+	router.Use(
+		Authorize(Options{
+			HeaderAuthProvider: XApiKeyHeader{},
+			SecretProvider:     NewEnvironmentSecretProviderReadWrite(envVariableName, ""),
+			HashedSecrets:      true,
+		}))
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	for requestKey, wantStatus := range map[string]int{
+		apiKeySecret(t): http.StatusNoContent,
+		h.String():      http.StatusUnauthorized,
+	} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/", nil)
+		require.NoError(t, err)
+		req.Header.Set(HeaderNameXApiKey, requestKey)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, wantStatus, resp.StatusCode)
+	}
+}
+
+func TestAPITokenAuth_ForbiddenHandler(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(
+		Authorize(Options{
+			ReadOnly:           true,
+			HeaderAuthProvider: XApiKeyHeader{},
+			KeySetProvider:     StaticKeySet{{ID: "dashboard", Secret: apiKeySecret(t), Scope: PermissionScopeReadonly}},
+			FailureHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			ForbiddenHandler: func(w http.ResponseWriter, r *http.Request) {
+				reason, _ := FailureReasonFromContext(r.Context())
+				w.WriteHeader(http.StatusTeapot)
+				_, err := w.Write([]byte(reason))
+				require.NoError(t, err)
+			},
+		}))
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		requestKey string
+		wantStatus int
+		wantHeader string
+	}{
+		{name: "forbidden", requestKey: apiKeySecret(t), wantStatus: http.StatusTeapot, wantHeader: `Bearer error="insufficient_scope"`},
+		{name: "unauthenticated", requestKey: "wrong-key", wantStatus: http.StatusBadRequest, wantHeader: `Bearer error="invalid_token"`},
+		{name: "missing credential", wantStatus: http.StatusBadRequest, wantHeader: "Bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/", nil)
+			require.NoError(t, err)
+			if tt.requestKey != "" {
+				req.Header.Set(HeaderNameXApiKey, tt.requestKey)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantHeader, resp.Header.Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestBearerChallenge(t *testing.T) {
+	tests := []struct {
+		reason FailureReason
+		want   string
+	}{
+		{reason: FailureReasonMissingCredential, want: "Bearer"},
+		{reason: FailureReasonMalformedCredential, want: `Bearer error="invalid_request"`},
+		{reason: FailureReasonAmbiguousCredential, want: `Bearer error="invalid_request"`},
+		{reason: FailureReasonRequestTooLarge, want: `Bearer error="invalid_request"`},
+		{reason: FailureReasonInsufficientScope, want: `Bearer error="insufficient_scope"`},
+		{reason: FailureReasonInvalidKey, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonKeyExpired, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonKeyNotYetValid, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonAddressNotAllowed, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonInvalidSignature, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonStaleSignature, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonReplayedSignature, want: `Bearer error="invalid_token"`},
+		{reason: FailureReasonRateLimited, want: "Bearer"},
+		{reason: FailureReasonQuotaExceeded, want: "Bearer"},
+		{reason: FailureReasonLockedOut, want: "Bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			assert.Equal(t, tt.want, bearerChallenge(tt.reason))
+		})
+	}
+}
+
+func TestAPITokenAuth_Observer(t *testing.T) {
+	var events []Event
+	handler := Authorize(Options{
+		ReadOnly:           true,
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider:     StaticKeySet{{ID: "dashboard", Secret: apiKeySecret(t), Scope: PermissionScopeReadonly}},
+		Observer: ObserverFunc(func(r *http.Request, e Event) {
+			events = append(events, e)
+		}),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set(HeaderNameXApiKey, apiKeySecret(t))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Len(t, events, 2)
+	assert.Equal(t, OutcomeAuthenticated, events[0].Outcome)
+	assert.Equal(t, "dashboard", events[0].Key.ID)
+	assert.Equal(t, OutcomeForbidden, events[1].Outcome)
+	assert.Equal(t, FailureReasonInsufficientScope, events[1].Reason)
+}
+
+func TestAuthorize_ReadonlyDeprecationExpirationPolicy(t *testing.T) {
+	expired, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(-time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	active, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+
+	for _, hashed := range []bool{false, true} {
+		provider := testSecretProvider{deprecatedSecret: "deprecated", deprecatedReadonlySecret: "readonly-deprecated"}
+		if hashed {
+			deprecated, err := NewSHA256KeyHash("deprecated")
+			require.NoError(t, err)
+			readonlyDeprecated, err := NewSHA256KeyHash("readonly-deprecated")
+			require.NoError(t, err)
+			provider = testSecretProvider{deprecatedSecret: deprecated.String(), deprecatedReadonlySecret: readonlyDeprecated.String()}
+		}
+		handler := Authorize(Options{
+			ReadOnly:                            true,
+			SecretProvider:                      provider,
+			DeprecationExpirationPolicy:         expired,
+			ReadonlyDeprecationExpirationPolicy: &active,
+			HashedSecrets:                       hashed,
+			HeaderAuthProvider:                  XApiKeyHeader{},
+		})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		send := func(secret string) int {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(HeaderNameXApiKey, secret)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusNoContent, send("readonly-deprecated"), "hashed=%t", hashed)
+		assert.Equal(t, http.StatusUnauthorized, send("deprecated"), "hashed=%t", hashed)
+	}
+}
+
+func TestAuthorize_Readonly_DeprecatedReadWriteSecret(t *testing.T) {
+	active, err := NewDeprecationExpirationPolicyFromString(time.Now().Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+
+	for _, hashed := range []bool{false, true} {
+		secret := "deprecated"
+		if hashed {
+			h, err := NewSHA256KeyHash(secret)
+			require.NoError(t, err)
+			secret = h.String()
+		}
+		options := NewReadonlyOptions()
+		options.SecretProvider = testSecretProvider{deprecatedSecret: secret}
+		options.DeprecationExpirationPolicy = active
+		options.HashedSecrets = hashed
+		options.HeaderAuthProvider = XApiKeyHeader{}
+		handler := Authorize(options)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			r := httptest.NewRequest(method, "/", nil)
+			r.Header.Set(HeaderNameXApiKey, "deprecated")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, "%s hashed=%t", method, hashed)
+		}
+	}
+}
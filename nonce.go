@@ -0,0 +1,72 @@
+package apikey
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceStore records the request signatures that have already been used, to reject replayed requests.
+// Implementations must be safe for concurrent use; a shared store (e.g. Redis) is required
+// when the middleware runs on more than one instance.
+type NonceStore interface {
+	// Remember records the nonce until expiresAt, reporting false if it has already been recorded
+	// and has not expired at now. Both times are given by the clock of the middleware.
+	Remember(nonce string, now, expiresAt time.Time) bool
+}
+
+const DefaultNonceSweepInterval = time.Minute
+
+// MemoryNonceStore is an in-memory NonceStore. Expired nonces are removed periodically, every SweepInterval.
+// The zero value is ready to use.
+type MemoryNonceStore struct {
+	// SweepInterval is the minimum interval between removals of expired nonces; defaults to DefaultNonceSweepInterval.
+	SweepInterval time.Duration
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+var _ NonceStore = (*MemoryNonceStore)(nil)
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{SweepInterval: DefaultNonceSweepInterval}
+}
+
+func (s *MemoryNonceStore) Remember(nonce string, now, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nonces == nil {
+		s.nonces = make(map[string]time.Time)
+	}
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+	if existing, ok := s.nonces[nonce]; ok && now.Before(existing) {
+		return false
+	}
+	s.nonces[nonce] = expiresAt
+	return true
+}
+
+// Len returns the number of nonces currently held, including expired ones not yet swept.
+func (s *MemoryNonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.nonces)
+}
+
+func (s *MemoryNonceStore) sweep(now time.Time) {
+	for nonce, expiresAt := range s.nonces {
+		if !now.Before(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+	interval := s.SweepInterval
+	if interval <= 0 {
+		interval = DefaultNonceSweepInterval
+	}
+	s.nextSweep = now.Add(interval)
+}
@@ -0,0 +1,62 @@
+package apikey
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryNonceStore_Remember(t *testing.T) {
+	t.Parallel()
+
+	var s MemoryNonceStore
+	now := time.Unix(1740787200, 0)
+	expiresAt := now.Add(time.Minute)
+
+	assert.True(t, s.Remember("a", now, expiresAt))
+	assert.False(t, s.Remember("a", now, expiresAt), "Should reject a nonce seen before")
+	assert.False(t, s.Remember("a", expiresAt.Add(-time.Nanosecond), expiresAt))
+	assert.True(t, s.Remember("a", expiresAt, expiresAt.Add(time.Minute)), "Should accept a nonce once it has expired")
+	assert.True(t, s.Remember("b", now, expiresAt))
+}
+
+func TestMemoryNonceStore_Sweep(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryNonceStore()
+	now := time.Unix(1740787200, 0)
+	for i := range 10 {
+		s.Remember(strconv.Itoa(i), now, now.Add(time.Second))
+	}
+	now = now.Add(DefaultNonceSweepInterval + time.Second)
+	s.Remember("live", now, now.Add(time.Minute))
+
+	assert.Equal(t, 1, s.Len(), "Should remove expired nonces")
+}
+
+func TestMemoryNonceStore_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryNonceStore()
+	now := time.Now()
+	expiresAt := now.Add(time.Minute)
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Remember("nonce", now, expiresAt) {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), accepted.Load(), "Should accept a nonce exactly once")
+}
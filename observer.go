@@ -0,0 +1,28 @@
+package apikey
+
+import (
+	"net/http"
+	"time"
+)
+
+// Event describes an authorization decision taken by the Authorize middleware.
+type Event struct {
+	Decision
+	// Duration is the time spent extracting the credential and authorizing the request.
+	Duration time.Duration
+}
+
+// Observer is notified of every authorization decision taken by the Authorize middleware.
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	ObserveAuthorization(r *http.Request, e Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(r *http.Request, e Event)
+
+var _ Observer = ObserverFunc(nil)
+
+func (f ObserverFunc) ObserveAuthorization(r *http.Request, e Event) {
+	f(r, e)
+}
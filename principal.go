@@ -0,0 +1,48 @@
+package apikey
+
+import (
+	"context"
+)
+
+// Principal describes the key that authenticated a request.
+type Principal struct {
+	KeyID      string
+	Owner      string
+	Scope      PermissionScope
+	Scopes     []PermissionScope
+	Deprecated bool
+}
+
+func NewPrincipal(key Key) Principal {
+	return Principal{
+		KeyID:      key.ID,
+		Owner:      key.Owner,
+		Scope:      key.Scope,
+		Scopes:     key.Scopes,
+		Deprecated: key.Deprecated,
+	}
+}
+
+// HasScopes reports whether the principal has been granted all the given scopes.
+func (p Principal) HasScopes(scopes ...PermissionScope) bool {
+	for _, scope := range scopes {
+		if !hasScope(p.Scope, p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+type principalCtxKey struct{}
+
+var principalContextKey = principalCtxKey{} //nolint:gochecknoglobals
+
+func NewPrincipalContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// PrincipalFromContext returns the principal stored by the Authorize middleware for an authorized request.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(Principal)
+	return p, ok
+}
@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	p := NewPrincipal(Key{
+		ID:         "billing-service",
+		Secret:     "secret",
+		Owner:      "billing",
+		Scope:      PermissionScopeReadonly,
+		Deprecated: true,
+	})
+	got, ok := PrincipalFromContext(NewPrincipalContext(context.Background(), p))
+	assert.True(t, ok)
+	assert.Equal(t, Principal{
+		KeyID:      "billing-service",
+		Owner:      "billing",
+		Scope:      PermissionScopeReadonly,
+		Deprecated: true,
+	}, got)
+}
+
+func TestPrincipal_HasScopes(t *testing.T) {
+	t.Parallel()
+
+	p := Principal{Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"billing:read", "admin"}}
+	assert.True(t, p.HasScopes())
+	assert.True(t, p.HasScopes("billing:read", "admin"))
+	assert.True(t, p.HasScopes(PermissionScopeReadonly), "Read-write should imply readonly")
+	assert.False(t, p.HasScopes("billing:read", "billing:write"))
+
+	readonly := Principal{Scope: PermissionScopeReadonly}
+	assert.True(t, readonly.HasScopes(PermissionScopeReadonly))
+	assert.False(t, readonly.HasScopes(PermissionScopeReadWrite))
+}
@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const ContentTypeProblemJSON = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 problem document, extended with the failure reason and the request ID.
+type ProblemDetails struct {
+	Type      string        `json:"type"`
+	Title     string        `json:"title"`
+	Status    int           `json:"status"`
+	Detail    string        `json:"detail,omitempty"`
+	Reason    FailureReason `json:"reason,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+func (r FailureReason) detail() string {
+	switch r {
+	case FailureReasonMissingCredential:
+		return "No API key was provided."
+	case FailureReasonMalformedCredential:
+		return "The API key credential is malformed."
+	case FailureReasonAmbiguousCredential:
+		return "Conflicting API keys were provided."
+	case FailureReasonInvalidKey:
+		return "The API key is invalid."
+	case FailureReasonKeyExpired:
+		return "The API key has expired."
+	case FailureReasonKeyNotYetValid:
+		return "The API key is not valid yet."
+	case FailureReasonInsufficientScope:
+		return "The API key does not grant access to this resource."
+	case FailureReasonAddressNotAllowed:
+		return "The API key is not accepted from this address."
+	case FailureReasonInvalidSignature:
+		return "The request signature is invalid."
+	case FailureReasonStaleSignature:
+		return "The request signature timestamp is outside the allowed clock skew."
+	case FailureReasonReplayedSignature:
+		return "The request signature has already been used."
+	case FailureReasonRequestTooLarge:
+		return "The request body is too large to verify its signature."
+	case FailureReasonRateLimited:
+		return "The API key has exceeded its rate limit."
+	case FailureReasonQuotaExceeded:
+		return "The API key has exhausted its request quota."
+	case FailureReasonLockedOut:
+		return "Too many failed attempts; retry later."
+	default:
+		return ""
+	}
+}
+
+func (r FailureReason) status() int {
+	switch r { //nolint:exhaustive // the remaining reasons are authentication failures
+	case FailureReasonInsufficientScope, FailureReasonAddressNotAllowed:
+		return http.StatusForbidden
+	case FailureReasonRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case FailureReasonRateLimited, FailureReasonQuotaExceeded, FailureReasonLockedOut:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// ProblemDetailsHandler returns a failure handler responding with an application/problem+json document.
+// The request ID is taken from the chi RequestID middleware, or the X-Request-Id request header.
+func ProblemDetailsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reason, ok := FailureReasonFromContext(r.Context())
+		if !ok {
+			reason = FailureReasonInvalidKey
+		}
+		requestID := middleware.GetReqID(r.Context())
+		if requestID == "" {
+			requestID = r.Header.Get(middleware.RequestIDHeader)
+		}
+		status := reason.status()
+		problem := ProblemDetails{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    reason.detail(),
+			Reason:    reason,
+			RequestID: requestID,
+		}
+		w.Header().Set("Content-Type", ContentTypeProblemJSON)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(problem)
+	}
+}
@@ -0,0 +1,91 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetailsHandler(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(Authorize(Options{
+		HeaderAuthProvider: AuthorizationHeader{},
+		FailureHandler:     ProblemDetailsHandler(),
+		ReadOnly:           true,
+		KeySetProvider: StaticKeySet{
+			{ID: "dashboard", Secret: "readonly-key", Scope: PermissionScopeReadonly},
+			{ID: "retired", Secret: "retired-key", Scope: PermissionScopeReadWrite, ExpiresAt: time.Now().Add(-time.Hour)},
+		},
+	}))
+	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantReason    FailureReason
+	}{
+		{name: "missing credential", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonMissingCredential},
+		{name: "malformed credential", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonMalformedCredential},
+		{name: "invalid key", authorization: "Bearer wrong-key", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonInvalidKey},
+		{name: "expired key", authorization: "Bearer retired-key", wantStatus: http.StatusUnauthorized, wantReason: FailureReasonKeyExpired},
+		{name: "insufficient scope", authorization: "Bearer readonly-key", wantStatus: http.StatusForbidden, wantReason: FailureReasonInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/", nil)
+			require.NoError(t, err)
+			if tt.authorization != "" {
+				req.Header.Set(HeaderNameAuthorization, tt.authorization)
+			}
+			req.Header.Set(middleware.RequestIDHeader, "request-"+tt.name)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = resp.Body.Close()
+			})
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, ContentTypeProblemJSON, resp.Header.Get("Content-Type"))
+
+			var problem ProblemDetails
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, ProblemDetails{
+				Type:      "about:blank",
+				Title:     http.StatusText(tt.wantStatus),
+				Status:    tt.wantStatus,
+				Detail:    tt.wantReason.detail(),
+				Reason:    tt.wantReason,
+				RequestID: "request-" + tt.name,
+			}, problem)
+		})
+	}
+}
+
+func TestProblemDetailsHandler_WithoutReason(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "request-id")
+	ProblemDetailsHandler()(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t,
+		`{"type":"about:blank","title":"Unauthorized","status":401,"detail":"The API key is invalid.","reason":"invalid_key","request_id":"request-id"}`,
+		rec.Body.String())
+}
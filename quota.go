@@ -0,0 +1,165 @@
+package apikey
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quota response headers.
+const (
+	HeaderNameQuotaLimit     = "X-Quota-Limit"
+	HeaderNameQuotaRemaining = "X-Quota-Remaining"
+	HeaderNameQuotaReset     = "X-Quota-Reset"
+)
+
+// QuotaPeriod is the calendar window requests are counted in.
+type QuotaPeriod string
+
+const (
+	QuotaPeriodDaily   = QuotaPeriod("daily")
+	QuotaPeriodMonthly = QuotaPeriod("monthly")
+)
+
+// QuotaWindow is a calendar window of a quota, starting at Start (inclusive) and ending at End (exclusive).
+type QuotaWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// window returns the calendar window t falls in, in the given location.
+// Unknown periods have no window.
+func (p QuotaPeriod) window(t time.Time, loc *time.Location) (QuotaWindow, bool) {
+	t = t.In(loc)
+	switch p {
+	case QuotaPeriodDaily:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return QuotaWindow{Start: start, End: start.AddDate(0, 0, 1)}, true
+	case QuotaPeriodMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return QuotaWindow{Start: start, End: start.AddDate(0, 1, 0)}, true
+	default:
+		return QuotaWindow{}, false
+	}
+}
+
+// Quota allows Limit requests per calendar Period. A Quota with a non-positive Limit does not limit requests.
+type Quota struct {
+	Limit  int64
+	Period QuotaPeriod
+}
+
+// QuotaStore counts the requests of each key per window.
+// Implementations backed by a database allow counts to survive restarts and to be shared between instances.
+type QuotaStore interface {
+	// Increment counts a request of the given key in the window, returning the number of requests counted so far.
+	Increment(key string, window QuotaWindow) (int64, error)
+}
+
+// QuotaOptions configures the Quota middleware.
+// The quota of a request is the first one found by LimitFunc, KeyQuotas, ScopeQuotas (primary scope first) and DefaultQuota.
+type QuotaOptions struct {
+	Store QuotaStore
+	// LimitFunc determines the quota from the principal, e.g. from its owner.
+	LimitFunc    func(p Principal) (Quota, bool)
+	KeyQuotas    map[string]Quota
+	ScopeQuotas  map[PermissionScope]Quota
+	DefaultQuota Quota
+	// Location determines the boundaries of calendar windows; defaults to UTC.
+	Location *time.Location
+	// Clock provides the current time for calendar windows; defaults to the system clock.
+	Clock Clock
+	// FailureHandler responds to requests exceeding their quota; defaults to DefaultRateLimitedHandler.
+	FailureHandler http.HandlerFunc
+	// ErrorHandler, when set, is called with the errors of the store, e.g. to log them or to alert on them,
+	// before the request is allowed.
+	ErrorHandler func(r *http.Request, err error)
+}
+
+func (o QuotaOptions) quota(p Principal) Quota {
+	return resolveLimit(p, o.LimitFunc, o.KeyQuotas, o.ScopeQuotas, o.DefaultQuota)
+}
+
+// RequireQuota returns a middleware that counts the requests of each key in calendar windows,
+// rejecting them with 429 Too Many Requests once the quota of the key has been exhausted.
+// It must be used after Authorize; requests without a principal are not counted.
+// Requests are allowed when the store fails, so that the API remains available during an outage of the store.
+func RequireQuota(options QuotaOptions) func(next http.Handler) http.Handler {
+	if options.Store == nil {
+		options.Store = NewMemoryQuotaStore()
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if options.FailureHandler == nil {
+		options.FailureHandler = DefaultRateLimitedHandler()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			quota := options.quota(p)
+			now := clockNow(options.Clock)
+			window, ok := quota.Period.window(now, options.Location)
+			if quota.Limit <= 0 || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			count, err := options.Store.Increment(p.KeyID, window)
+			if err != nil {
+				if options.ErrorHandler != nil {
+					options.ErrorHandler(r, err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			reset := formatSeconds(window.End.Sub(now))
+			w.Header().Set(HeaderNameQuotaLimit, strconv.FormatInt(quota.Limit, 10))
+			w.Header().Set(HeaderNameQuotaRemaining, strconv.FormatInt(max(quota.Limit-count, 0), 10))
+			w.Header().Set(HeaderNameQuotaReset, reset)
+			if count > quota.Limit {
+				w.Header().Set(HeaderNameRetryAfter, reset)
+				options.FailureHandler(w, r.WithContext(NewFailureContext(r.Context(), FailureReasonQuotaExceeded)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MemoryQuotaStore is an in-memory QuotaStore; counts are lost on restart. The zero value is ready to use.
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	counts map[string]quotaCount
+}
+
+type quotaCount struct {
+	start time.Time
+	count int64
+}
+
+var _ QuotaStore = (*MemoryQuotaStore)(nil)
+
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{}
+}
+
+func (s *MemoryQuotaStore) Increment(key string, window QuotaWindow) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		s.counts = make(map[string]quotaCount)
+	}
+	c := s.counts[key]
+	if !c.start.Equal(window.Start) {
+		c = quotaCount{start: window.Start}
+	}
+	c.count++
+	s.counts[key] = c
+	return c.count, nil
+}
@@ -0,0 +1,159 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaPeriod_window(t *testing.T) {
+	t.Parallel()
+
+	athens, err := time.LoadLocation("Europe/Athens")
+	require.NoError(t, err)
+	now := time.Date(2025, time.January, 31, 23, 30, 0, 0, time.UTC)
+
+	w, ok := QuotaPeriodDaily.window(now, time.UTC)
+	require.True(t, ok)
+	assert.Equal(t, QuotaWindow{
+		Start: time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}, w)
+
+	w, ok = QuotaPeriodMonthly.window(now, time.UTC)
+	require.True(t, ok)
+	assert.Equal(t, QuotaWindow{
+		Start: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}, w)
+
+	w, ok = QuotaPeriodMonthly.window(now, athens)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, time.February, 1, 0, 0, 0, 0, athens), w.Start, "Should follow the calendar of the location")
+	assert.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, athens), w.End)
+
+	_, ok = QuotaPeriod("weekly").window(now, time.UTC)
+	assert.False(t, ok)
+}
+
+func TestMemoryQuotaStore_Increment(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryQuotaStore()
+	january, ok := QuotaPeriodMonthly.window(time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC), time.UTC)
+	require.True(t, ok)
+	february, ok := QuotaPeriodMonthly.window(time.Date(2025, time.February, 15, 0, 0, 0, 0, time.UTC), time.UTC)
+	require.True(t, ok)
+
+	for i := range 3 {
+		count, err := s.Increment("a", january)
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), count)
+	}
+	count, err := s.Increment("b", january)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "Should count requests per key")
+
+	count, err = s.Increment("a", february)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "Should reset the count in a new window")
+}
+
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Increment(string, QuotaWindow) (int64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func TestRequireQuota(t *testing.T) {
+	t.Parallel()
+
+	newHandler := func(options QuotaOptions) http.Handler {
+		return Authorize(Options{
+			KeySetProvider: StaticKeySet{
+				{ID: "free", Secret: "free-secret", Scope: PermissionScopeReadWrite},
+				{ID: "paid", Secret: "paid-secret", Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"tier:paid"}},
+			},
+			HeaderAuthProvider: XApiKeyHeader{},
+		})(RequireQuota(options)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+	}
+	send := func(handler http.Handler, secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("quota per scope", func(t *testing.T) {
+		t.Parallel()
+
+		handler := newHandler(QuotaOptions{
+			DefaultQuota:   Quota{Limit: 2, Period: QuotaPeriodDaily},
+			ScopeQuotas:    map[PermissionScope]Quota{"tier:paid": {Limit: 100, Period: QuotaPeriodMonthly}},
+			FailureHandler: ProblemDetailsHandler(),
+		})
+
+		w := send(handler, "free-secret")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "2", w.Header().Get(HeaderNameQuotaLimit))
+		assert.Equal(t, "1", w.Header().Get(HeaderNameQuotaRemaining))
+		reset, err := strconv.Atoi(w.Header().Get(HeaderNameQuotaReset))
+		require.NoError(t, err)
+		assert.Positive(t, reset)
+		assert.LessOrEqual(t, reset, int(24*time.Hour.Seconds()), "Should reset at the end of the day")
+
+		w = send(handler, "free-secret")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "0", w.Header().Get(HeaderNameQuotaRemaining))
+
+		w = send(handler, "free-secret")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "0", w.Header().Get(HeaderNameQuotaRemaining))
+		assert.Equal(t, w.Header().Get(HeaderNameQuotaReset), w.Header().Get(HeaderNameRetryAfter))
+		assert.Contains(t, w.Body.String(), `"reason":"quota_exceeded"`)
+
+		w = send(handler, "paid-secret")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "100", w.Header().Get(HeaderNameQuotaLimit))
+		assert.Equal(t, "99", w.Header().Get(HeaderNameQuotaRemaining))
+	})
+
+	t.Run("no quota", func(t *testing.T) {
+		t.Parallel()
+
+		handler := newHandler(QuotaOptions{KeyQuotas: map[string]Quota{"free": {Limit: 1, Period: QuotaPeriodDaily}}})
+		for range 3 {
+			w := send(handler, "paid-secret")
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Empty(t, w.Header().Get(HeaderNameQuotaLimit))
+		}
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		t.Parallel()
+
+		var storeErrors int
+		handler := newHandler(QuotaOptions{
+			Store:        failingQuotaStore{},
+			DefaultQuota: Quota{Limit: 1, Period: QuotaPeriodDaily},
+			ErrorHandler: func(r *http.Request, err error) {
+				storeErrors++
+				assert.EqualError(t, err, "store unavailable")
+				assert.NotNil(t, r)
+			},
+		})
+		for range 3 {
+			assert.Equal(t, http.StatusNoContent, send(handler, "free-secret").Code, "Should allow requests when the store fails")
+		}
+		assert.Equal(t, 3, storeErrors, "Should report every store error")
+	})
+}
@@ -0,0 +1,244 @@
+package apikey
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limiting response headers, following the IETF RateLimit header fields draft.
+const (
+	HeaderNameRateLimitLimit     = "RateLimit-Limit"
+	HeaderNameRateLimitRemaining = "RateLimit-Remaining"
+	HeaderNameRateLimitReset     = "RateLimit-Reset"
+	HeaderNameRetryAfter         = "Retry-After"
+)
+
+// Rate is a token bucket allowing Limit requests per Period, with bursts of up to Burst requests.
+// A Rate with a non-positive Limit or Period does not limit requests.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	// Burst is the capacity of the bucket; defaults to Limit.
+	Burst int
+}
+
+func (r Rate) unlimited() bool {
+	return r.Limit <= 0 || r.Period <= 0
+}
+
+func (r Rate) burst() int {
+	if r.Burst <= 0 {
+		return r.Limit
+	}
+	return r.Burst
+}
+
+// perSecond returns the number of tokens added to the bucket every second.
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// RateLimitResult is the state of a token bucket after taking a token from it.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, for requests that have not been allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets of each key.
+// Implementations must be safe for concurrent use; a shared store (e.g. Redis) is required
+// when the middleware runs on more than one instance.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of the given key.
+	Take(key string, rate Rate, now time.Time) RateLimitResult
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+// The rate of a request is the first one found by LimitFunc, KeyRates, ScopeRates (primary scope first) and DefaultRate.
+type RateLimitOptions struct {
+	Store RateLimitStore
+	// LimitFunc determines the rate from the principal, e.g. from its owner.
+	LimitFunc   func(p Principal) (Rate, bool)
+	KeyRates    map[string]Rate
+	ScopeRates  map[PermissionScope]Rate
+	DefaultRate Rate
+	// Clock provides the current time for refilling buckets; defaults to the system clock.
+	Clock Clock
+	// FailureHandler responds to limited requests; defaults to DefaultRateLimitedHandler.
+	FailureHandler http.HandlerFunc
+}
+
+func (o RateLimitOptions) rate(p Principal) Rate {
+	return resolveLimit(p, o.LimitFunc, o.KeyRates, o.ScopeRates, o.DefaultRate)
+}
+
+// resolveLimit returns the first limit of the principal found by limitFunc, in keyLimits,
+// in scopeLimits (primary scope first) and finally defaultLimit.
+func resolveLimit[L any](
+	p Principal,
+	limitFunc func(p Principal) (L, bool),
+	keyLimits map[string]L,
+	scopeLimits map[PermissionScope]L,
+	defaultLimit L,
+) L {
+	if limitFunc != nil {
+		if limit, ok := limitFunc(p); ok {
+			return limit
+		}
+	}
+	if limit, ok := keyLimits[p.KeyID]; ok {
+		return limit
+	}
+	if limit, ok := scopeLimits[p.Scope]; ok {
+		return limit
+	}
+	for _, scope := range p.Scopes {
+		if limit, ok := scopeLimits[scope]; ok {
+			return limit
+		}
+	}
+	return defaultLimit
+}
+
+// RateLimit returns a middleware that limits the rate of requests per key, using the key ID of the principal.
+// It must be used after Authorize; requests without a principal are not limited.
+// Responses carry the RateLimit-* headers, and limited requests also carry a Retry-After header.
+func RateLimit(options RateLimitOptions) func(next http.Handler) http.Handler {
+	if options.Store == nil {
+		options.Store = NewMemoryRateLimitStore()
+	}
+	if options.FailureHandler == nil {
+		options.FailureHandler = DefaultRateLimitedHandler()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rate := options.rate(p)
+			if rate.unlimited() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			result := options.Store.Take(p.KeyID, rate, clockNow(options.Clock))
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
+				w.Header().Set(HeaderNameRetryAfter, formatSeconds(result.RetryAfter))
+				options.FailureHandler(w, r.WithContext(NewFailureContext(r.Context(), FailureReasonRateLimited)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func DefaultRateLimitedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, result RateLimitResult) {
+	w.Header().Set(HeaderNameRateLimitLimit, strconv.Itoa(result.Limit))
+	w.Header().Set(HeaderNameRateLimitRemaining, strconv.Itoa(result.Remaining))
+	w.Header().Set(HeaderNameRateLimitReset, formatSeconds(result.Reset))
+}
+
+// formatSeconds formats the duration as a whole number of seconds, rounded up.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+const DefaultRateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is an in-memory RateLimitStore. Buckets that have been refilled are removed periodically,
+// every SweepInterval. The zero value is ready to use.
+type MemoryRateLimitStore struct {
+	// SweepInterval is the minimum interval between removals of full buckets; defaults to DefaultRateLimitSweepInterval.
+	SweepInterval time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time the bucket is refilled, after which it can be discarded.
+	full time.Time
+}
+
+var _ RateLimitStore = (*MemoryRateLimitStore)(nil)
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{SweepInterval: DefaultRateLimitSweepInterval}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rate Rate, now time.Time) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*tokenBucket)
+	}
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+	capacity := float64(rate.burst())
+	perSecond := rate.perSecond()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()*perSecond)
+		b.updated = now
+	}
+	result := RateLimitResult{Limit: rate.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / perSecond)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsDuration((capacity - b.tokens) / perSecond)
+	b.full = now.Add(result.Reset)
+	return result
+}
+
+// Len returns the number of buckets currently held.
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.buckets)
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	interval := s.SweepInterval
+	if interval <= 0 {
+		interval = DefaultRateLimitSweepInterval
+	}
+	s.nextSweep = now.Add(interval)
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
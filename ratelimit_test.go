@@ -0,0 +1,167 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimitStore_Take(t *testing.T) {
+	t.Parallel()
+
+	var s MemoryRateLimitStore
+	rate := Rate{Limit: 2, Period: time.Second}
+	now := time.Unix(1740787200, 0)
+
+	r := s.Take("a", rate, now)
+	assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 500 * time.Millisecond}, r)
+	r = s.Take("a", rate, now)
+	assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Second}, r)
+	r = s.Take("a", rate, now)
+	assert.Equal(t, RateLimitResult{Limit: 2, Remaining: 0, Reset: time.Second, RetryAfter: 500 * time.Millisecond}, r)
+
+	assert.True(t, s.Take("b", rate, now).Allowed, "Should keep a bucket per key")
+
+	r = s.Take("a", rate, now.Add(500*time.Millisecond))
+	assert.True(t, r.Allowed, "Should refill the bucket over time")
+	assert.Equal(t, 0, r.Remaining)
+
+	r = s.Take("a", rate, now.Add(time.Hour))
+	assert.True(t, r.Allowed)
+	assert.Equal(t, 1, r.Remaining, "Should not refill beyond the bucket capacity")
+}
+
+func TestMemoryRateLimitStore_Burst(t *testing.T) {
+	t.Parallel()
+
+	var s MemoryRateLimitStore
+	rate := Rate{Limit: 1, Period: time.Minute, Burst: 3}
+	now := time.Now()
+
+	for range 3 {
+		assert.True(t, s.Take("a", rate, now).Allowed)
+	}
+	r := s.Take("a", rate, now)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, time.Minute, r.RetryAfter)
+}
+
+func TestMemoryRateLimitStore_Sweep(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryRateLimitStore()
+	rate := Rate{Limit: 10, Period: time.Second}
+	now := time.Unix(1740787200, 0)
+
+	s.Take("a", rate, now)
+	s.Take("b", rate, now)
+	require.Equal(t, 2, s.Len())
+
+	s.Take("c", rate, now.Add(DefaultRateLimitSweepInterval+time.Second))
+	assert.Equal(t, 1, s.Len(), "Should remove refilled buckets")
+}
+
+func TestMemoryRateLimitStore_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	s := NewMemoryRateLimitStore()
+	rate := Rate{Limit: 10, Period: time.Hour}
+	now := time.Now()
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Take("a", rate, now).Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, allowed)
+}
+
+func TestRateLimitOptions_rate(t *testing.T) {
+	t.Parallel()
+
+	options := RateLimitOptions{
+		LimitFunc: func(p Principal) (Rate, bool) {
+			return Rate{Limit: 1, Period: time.Second}, p.Owner == "partner"
+		},
+		KeyRates:    map[string]Rate{"batch": {Limit: 2, Period: time.Second}},
+		ScopeRates:  map[PermissionScope]Rate{PermissionScopeReadonly: {Limit: 3, Period: time.Second}, "tier:gold": {Limit: 4, Period: time.Second}},
+		DefaultRate: Rate{Limit: 5, Period: time.Second},
+	}
+
+	tests := []struct {
+		principal Principal
+		wantLimit int
+	}{
+		{principal: Principal{KeyID: "batch", Owner: "partner"}, wantLimit: 1},
+		{principal: Principal{KeyID: "batch", Scope: PermissionScopeReadonly}, wantLimit: 2},
+		{principal: Principal{KeyID: "a", Scope: PermissionScopeReadonly, Scopes: []PermissionScope{"tier:gold"}}, wantLimit: 3},
+		{principal: Principal{KeyID: "a", Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"tier:gold"}}, wantLimit: 4},
+		{principal: Principal{KeyID: "a", Scope: PermissionScopeReadWrite}, wantLimit: 5},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.wantLimit, options.rate(tt.principal).Limit, tt.principal)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		KeySetProvider: StaticKeySet{
+			{ID: "limited", Secret: "limited-secret", Scope: PermissionScopeReadWrite},
+			{ID: "unlimited", Secret: "unlimited-secret", Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"internal"}},
+		},
+		HeaderAuthProvider: XApiKeyHeader{},
+	})(RateLimit(RateLimitOptions{
+		ScopeRates:     map[PermissionScope]Rate{"internal": {}},
+		DefaultRate:    Rate{Limit: 2, Period: time.Minute},
+		FailureHandler: ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	send := func(secret string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderNameXApiKey, secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send("limited-secret")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "2", w.Header().Get(HeaderNameRateLimitLimit))
+	assert.Equal(t, "1", w.Header().Get(HeaderNameRateLimitRemaining))
+	assert.Equal(t, "30", w.Header().Get(HeaderNameRateLimitReset))
+	assert.Empty(t, w.Header().Get(HeaderNameRetryAfter))
+
+	assert.Equal(t, http.StatusNoContent, send("limited-secret").Code)
+
+	w = send("limited-secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderNameRateLimitRemaining))
+	assert.Equal(t, "30", w.Header().Get(HeaderNameRetryAfter))
+	assert.Contains(t, w.Body.String(), `"reason":"rate_limited"`)
+
+	for range 5 {
+		w = send("unlimited-secret")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get(HeaderNameRateLimitLimit))
+	}
+}
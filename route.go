@@ -0,0 +1,54 @@
+package apikey
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RouteRule defines the key scopes accepted for requests matching a chi route pattern, e.g. "/users/{id}".
+type RouteRule struct {
+	Pattern string
+	// Methods the rule applies to. An empty list applies the rule to all HTTP methods.
+	Methods []string
+	// Scopes accepted for matching requests; a key needs to have been granted any of them.
+	// The rule replaces the method-based check of the key scope.
+	Scopes []PermissionScope
+}
+
+func (rule RouteRule) matches(httpMethod, pattern string) bool {
+	return rule.Pattern == pattern && (len(rule.Methods) == 0 || slices.Contains(rule.Methods, httpMethod))
+}
+
+// RoutePolicy is a table of route rules; the first rule matching a request applies.
+type RoutePolicy []RouteRule
+
+func (p RoutePolicy) Match(httpMethod, pattern string) (RouteRule, bool) {
+	for _, rule := range p {
+		if rule.matches(httpMethod, pattern) {
+			return rule, true
+		}
+	}
+	return RouteRule{}, false
+}
+
+// routePattern returns the chi route pattern of the request.
+// Middlewares registered with Use run before chi has resolved the route,
+// so the pattern is looked up on the routing tree instead.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	if rctx.Routes != nil {
+		path := r.URL.RawPath
+		if path == "" {
+			path = r.URL.Path
+		}
+		if pattern := rctx.Routes.Find(chi.NewRouteContext(), r.Method, path); pattern != "" {
+			return pattern
+		}
+	}
+	return rctx.RoutePattern()
+}
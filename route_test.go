@@ -0,0 +1,112 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutePolicy_Match(t *testing.T) {
+	t.Parallel()
+
+	policy := RoutePolicy{
+		{Pattern: "/search", Methods: []string{http.MethodPost}, Scopes: []PermissionScope{PermissionScopeReadonly}},
+		{Pattern: "/admin/*", Scopes: []PermissionScope{"admin"}},
+	}
+
+	rule, ok := policy.Match(http.MethodPost, "/search")
+	assert.True(t, ok)
+	assert.Equal(t, policy[0], rule)
+
+	_, ok = policy.Match(http.MethodDelete, "/search")
+	assert.False(t, ok)
+
+	rule, ok = policy.Match(http.MethodDelete, "/admin/*")
+	assert.True(t, ok)
+	assert.Equal(t, policy[1], rule)
+
+	_, ok = policy.Match(http.MethodGet, "/")
+	assert.False(t, ok)
+}
+
+func TestAuthorize_RoutePolicy(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider: StaticKeySet{
+			{ID: "dashboard", Secret: "readonly-key", Scope: PermissionScopeReadonly},
+			{ID: "service", Secret: "readwrite-key", Scope: PermissionScopeReadWrite},
+			{ID: "admin", Secret: "admin-key", Scope: PermissionScopeReadonly, Scopes: []PermissionScope{"admin"}},
+		},
+		RoutePolicy: RoutePolicy{
+			{Pattern: "/search", Methods: []string{http.MethodPost}, Scopes: []PermissionScope{PermissionScopeReadonly}},
+			{Pattern: "/api/users/{id}", Methods: []string{http.MethodDelete}, Scopes: []PermissionScope{"admin"}},
+		},
+	}))
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	router.Post("/search", ok)
+	router.Post("/items", ok)
+	router.Route("/api", func(r chi.Router) {
+		r.Delete("/users/{id}", ok)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		requestKey string
+		want       int
+	}{
+		{name: "readonly key on read route", method: http.MethodPost, path: "/search", requestKey: "readonly-key", want: http.StatusNoContent},
+		{name: "read-write key on read route", method: http.MethodPost, path: "/search", requestKey: "readwrite-key", want: http.StatusNoContent},
+		{name: "readonly key without route rule", method: http.MethodPost, path: "/items", requestKey: "readonly-key", want: http.StatusForbidden},
+		{name: "read-write key without route rule", method: http.MethodPost, path: "/items", requestKey: "readwrite-key", want: http.StatusNoContent},
+		{name: "mounted route with custom scope", method: http.MethodDelete, path: "/api/users/1", requestKey: "admin-key", want: http.StatusNoContent},
+		{name: "mounted route without custom scope", method: http.MethodDelete, path: "/api/users/1", requestKey: "readwrite-key", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), tt.method, srv.URL+tt.path, nil)
+			require.NoError(t, err)
+			req.Header.Set(HeaderNameXApiKey, tt.requestKey)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	t.Parallel()
+
+	var patterns []string
+	record := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			patterns = append(patterns, routePattern(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+	router := chi.NewRouter()
+	router.Use(record)
+	router.Route("/api", func(r chi.Router) {
+		r.Use(record)
+		r.With(record).Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/users/1", nil))
+	assert.Equal(t, []string{"/api/users/{id}", "/api/users/{id}", "/api/users/{id}"}, patterns)
+
+	assert.Empty(t, routePattern(httptest.NewRequest(http.MethodGet, "/", nil)))
+}
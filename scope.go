@@ -0,0 +1,28 @@
+package apikey
+
+import (
+	"net/http"
+)
+
+// RequireScopes returns a middleware that only allows requests whose principal has been granted all the given scopes.
+// It must be used after Authorize; requests are otherwise rejected with 403 Forbidden.
+func RequireScopes(scopes ...PermissionScope) func(next http.Handler) http.Handler {
+	return RequireScopesWithFailureHandler(DefaultForbiddenHandler(), scopes...)
+}
+
+func RequireScopesWithFailureHandler(failureHandler http.HandlerFunc, scopes ...PermissionScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				failureHandler(w, r.WithContext(NewFailureContext(r.Context(), FailureReasonMissingCredential)))
+				return
+			}
+			if !p.HasScopes(scopes...) {
+				failureHandler(w, r.WithContext(NewFailureContext(r.Context(), FailureReasonInsufficientScope)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireScopes(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider: StaticKeySet{
+			{ID: "billing", Secret: "billing-key", Scope: PermissionScopeReadonly, Scopes: []PermissionScope{"billing:read"}},
+			{ID: "admin", Secret: "admin-key", Scope: PermissionScopeReadWrite, Scopes: []PermissionScope{"admin", "billing:read"}},
+		},
+		ReadOnly: true,
+	}))
+	router.With(RequireScopes("billing:read")).Get("/invoices", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.With(RequireScopes("admin")).Get("/admin", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		requestKey string
+		want       int
+	}{
+		{name: "granted scope", path: "/invoices", requestKey: "billing-key", want: http.StatusNoContent},
+		{name: "missing scope", path: "/admin", requestKey: "billing-key", want: http.StatusForbidden},
+		{name: "all scopes", path: "/admin", requestKey: "admin-key", want: http.StatusNoContent},
+		{name: "invalid key", path: "/invoices", requestKey: "wrong-key", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+tt.path, nil)
+			require.NoError(t, err)
+			req.Header.Set(HeaderNameXApiKey, tt.requestKey)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestRequireScopes_WithoutPrincipal(t *testing.T) {
+	t.Parallel()
+
+	handler := RequireScopes("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -1,6 +1,7 @@
 package apikey
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"strings"
@@ -84,6 +85,27 @@ type HeaderAuthProvider interface {
 	Secret(r *http.Request) (string, bool)
 }
 
+var (
+	ErrMissingCredential   = errors.New("apikey: missing credential")
+	ErrMalformedCredential = errors.New("apikey: malformed credential")
+)
+
+// SecretExtractor can be implemented by a HeaderAuthProvider to tell
+// a missing credential (ErrMissingCredential) apart from a malformed one (ErrMalformedCredential).
+type SecretExtractor interface {
+	ExtractSecret(r *http.Request) (string, error)
+}
+
+func extractSecret(p HeaderAuthProvider, r *http.Request) (string, error) {
+	if e, ok := p.(SecretExtractor); ok {
+		return e.ExtractSecret(r)
+	}
+	if secret, ok := p.Secret(r); ok {
+		return secret, nil
+	}
+	return "", ErrMissingCredential
+}
+
 type XApiKeyHeader struct {
 	HeaderAuthProvider
 }
@@ -97,8 +119,20 @@ func (h XApiKeyHeader) Name() string {
 }
 
 func (h XApiKeyHeader) Secret(r *http.Request) (string, bool) {
-	key := strings.TrimSpace(r.Header.Get(h.Name()))
-	return key, key != ""
+	key, err := h.ExtractSecret(r)
+	return key, err == nil
+}
+
+func (h XApiKeyHeader) ExtractSecret(r *http.Request) (string, error) {
+	values := r.Header.Values(h.Name())
+	if len(values) == 0 {
+		return "", ErrMissingCredential
+	}
+	key := strings.TrimSpace(values[0])
+	if key == "" {
+		return "", ErrMalformedCredential
+	}
+	return key, nil
 }
 
 type AuthorizationHeader struct {
@@ -113,10 +147,21 @@ func (h AuthorizationHeader) Name() string {
 }
 
 func (h AuthorizationHeader) Secret(r *http.Request) (string, bool) {
+	secret, err := h.ExtractSecret(r)
+	return secret, err == nil
+}
+
+func (h AuthorizationHeader) ExtractSecret(r *http.Request) (string, error) {
 	secret := r.Header.Get(h.Name())
+	if secret == "" {
+		return "", ErrMissingCredential
+	}
 	if !strings.HasPrefix(secret, bearerPrefix) {
-		return "", false
+		return "", ErrMalformedCredential
 	}
 	secret = strings.TrimSpace(strings.TrimPrefix(secret, bearerPrefix))
-	return secret, secret != ""
+	if secret == "" {
+		return "", ErrMalformedCredential
+	}
+	return secret, nil
 }
@@ -0,0 +1,118 @@
+package apikey
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFileReloadInterval is the minimum time between two checks of a secret file for changes.
+const DefaultFileReloadInterval = 5 * time.Second
+
+// FileSecretProvider reads secrets from files, e.g. a mounted Kubernetes secret volume.
+// Files are checked for changes at most once per ReloadInterval and re-read when
+// they have been replaced or modified, so keys can be rotated without a restart.
+type FileSecretProvider struct {
+	SecretProvider
+	CurrentSecretPath            string
+	DeprecatedSecretPath         string
+	ReadonlySecretPath           string
+	DeprecatedReadonlySecretPath string
+	// ReloadInterval is the minimum time between two checks of a file for changes.
+	// A zero value checks the file on every access.
+	ReloadInterval time.Duration
+	mu             sync.Mutex
+	files          map[string]*fileSecret
+}
+
+var _ SecretProvider = (*FileSecretProvider)(nil)
+
+type FileSecretProviderSettingPaths struct {
+	CurrentSecretPath            string
+	DeprecatedSecretPath         string
+	ReadonlySecretPath           string
+	DeprecatedReadonlySecretPath string
+}
+
+type fileSecret struct {
+	value     string
+	info      os.FileInfo
+	checkedAt time.Time
+	// err is the error of the last check of the file, if it could not be read.
+	err error
+}
+
+func NewFileSecretProvider(s FileSecretProviderSettingPaths) *FileSecretProvider {
+	return &FileSecretProvider{
+		CurrentSecretPath:            s.CurrentSecretPath,
+		DeprecatedSecretPath:         s.DeprecatedSecretPath,
+		ReadonlySecretPath:           s.ReadonlySecretPath,
+		DeprecatedReadonlySecretPath: s.DeprecatedReadonlySecretPath,
+		ReloadInterval:               DefaultFileReloadInterval,
+		files:                        map[string]*fileSecret{},
+	}
+}
+
+func (p *FileSecretProvider) GetCurrentSecret() string {
+	return p.load(p.CurrentSecretPath)
+}
+
+func (p *FileSecretProvider) GetDeprecatedSecret() string {
+	return p.load(p.DeprecatedSecretPath)
+}
+
+func (p *FileSecretProvider) GetCurrentReadonlySecret() string {
+	return p.load(p.ReadonlySecretPath)
+}
+
+func (p *FileSecretProvider) GetDeprecatedReadonlySecret() string {
+	return p.load(p.DeprecatedReadonlySecretPath)
+}
+
+func (p *FileSecretProvider) load(path string) string {
+	if path == "" {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.files == nil {
+		p.files = map[string]*fileSecret{}
+	}
+	f, ok := p.files[path]
+	if !ok {
+		f = &fileSecret{}
+		p.files[path] = f
+	}
+	f.refresh(path, time.Now(), p.ReloadInterval)
+	return f.value
+}
+
+// refresh re-reads the file when it has been replaced or modified since it was last read,
+// checking for changes at most once per reload interval. It reports whether the file has been checked.
+func (f *fileSecret) refresh(path string, now time.Time, reloadInterval time.Duration) bool {
+	if !f.checkedAt.IsZero() && now.Sub(f.checkedAt) < reloadInterval {
+		return false
+	}
+	f.checkedAt = now
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// Fail closed when the file disappears or cannot be accessed.
+		f.value, f.info, f.err = "", nil, err
+		return true
+	}
+	f.err = nil
+	if f.info != nil && os.SameFile(f.info, info) &&
+		f.info.ModTime().Equal(info.ModTime()) && f.info.Size() == info.Size() {
+		return true
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is provided by configuration
+	if err != nil {
+		f.value, f.info, f.err = "", nil, err
+		return true
+	}
+	f.value, f.info = strings.TrimSpace(string(data)), info
+	return true
+}
@@ -0,0 +1,100 @@
+package apikey
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecretFile(t *testing.T, path, value string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(value), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileSecretProvider_Load(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current")
+	readonly := filepath.Join(dir, "readonly")
+	writeSecretFile(t, current, "current-secret\n", time.Now().Add(-time.Hour))
+	writeSecretFile(t, readonly, "  readonly-secret  ", time.Now().Add(-time.Hour))
+
+	provider := NewFileSecretProvider(FileSecretProviderSettingPaths{
+		CurrentSecretPath:  current,
+		ReadonlySecretPath: readonly,
+	})
+
+	assert.Equal(t, "current-secret", provider.GetCurrentSecret())
+	assert.Equal(t, "readonly-secret", provider.GetCurrentReadonlySecret())
+	assert.Empty(t, provider.GetDeprecatedSecret())
+	assert.Empty(t, provider.GetDeprecatedReadonlySecret())
+}
+
+func TestFileSecretProvider_ReloadOnChange(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "current")
+	writeSecretFile(t, path, "first-secret", time.Now().Add(-time.Hour))
+
+	provider := NewFileSecretProvider(FileSecretProviderSettingPaths{CurrentSecretPath: path})
+	provider.ReloadInterval = 0
+
+	assert.Equal(t, "first-secret", provider.GetCurrentSecret())
+
+	writeSecretFile(t, path, "second-secret", time.Now())
+	assert.Equal(t, "second-secret", provider.GetCurrentSecret())
+
+	require.NoError(t, os.Remove(path))
+	assert.Empty(t, provider.GetCurrentSecret(), "Should fail closed when the file is removed")
+}
+
+func TestFileSecretProvider_ReloadOnSymlinkSwap(t *testing.T) {
+	t.Parallel()
+
+	// Mimics the layout of a Kubernetes secret volume, where the data directory
+	// is replaced atomically by swapping a symbolic link.
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "v1"), 0o700))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "v2"), 0o700))
+	writeSecretFile(t, filepath.Join(dir, "v1", "key"), "old-secret", modTime)
+	writeSecretFile(t, filepath.Join(dir, "v2", "key"), "new-secret", modTime)
+
+	data := filepath.Join(dir, "..data")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "v1"), data))
+	path := filepath.Join(dir, "key")
+	require.NoError(t, os.Symlink(filepath.Join(data, "key"), path))
+
+	provider := NewFileSecretProvider(FileSecretProviderSettingPaths{CurrentSecretPath: path})
+	provider.ReloadInterval = 0
+	assert.Equal(t, "old-secret", provider.GetCurrentSecret())
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "v2"), tmp))
+	require.NoError(t, os.Rename(tmp, data))
+
+	assert.Equal(t, "new-secret", provider.GetCurrentSecret())
+}
+
+func TestFileSecretProvider_ReloadInterval(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "current")
+	writeSecretFile(t, path, "first-secret", time.Now().Add(-time.Hour))
+
+	provider := NewFileSecretProvider(FileSecretProviderSettingPaths{CurrentSecretPath: path})
+	provider.ReloadInterval = time.Hour
+
+	assert.Equal(t, "first-secret", provider.GetCurrentSecret())
+
+	writeSecretFile(t, path, "second-secret", time.Now())
+	assert.Equal(t, "first-secret", provider.GetCurrentSecret(), "Should not check the file before the reload interval elapses")
+}
@@ -100,3 +100,31 @@ func TestEnvironmentSecretProvider_GetDeprecatedReadonlySecret(t *testing.T) {
 	secret := provider.GetDeprecatedReadonlySecret()
 	assert.Equal(t, "deprecated-readonly-value", secret)
 }
+
+func TestAuthorizationHeader_ExtractSecret(t *testing.T) {
+	tests := []struct {
+		name        string
+		headerValue string
+		wantSecret  string
+		wantErr     error
+	}{
+		{name: "bearer token", headerValue: "Bearer test-key-123", wantSecret: "test-key-123"},
+		{name: "missing header", headerValue: "", wantErr: ErrMissingCredential},
+		{name: "other scheme", headerValue: "Basic dXNlcjpwYXNz", wantErr: ErrMalformedCredential},
+		{name: "empty bearer token", headerValue: "Bearer   ", wantErr: ErrMalformedCredential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := http.Header{}
+			if tt.headerValue != "" {
+				h.Set(HeaderNameAuthorization, tt.headerValue)
+			}
+			secret, err := AuthorizationHeader{}.ExtractSecret(&http.Request{Header: h})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSecret, secret)
+		})
+	}
+}
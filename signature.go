@@ -0,0 +1,209 @@
+package apikey
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers of the HMAC request signing scheme.
+const (
+	HeaderNameSignature          = "X-Api-Signature"
+	HeaderNameSignatureTimestamp = "X-Api-Timestamp"
+	HeaderNameSignatureKeyID     = "X-Api-Key-Id"
+	HeaderNameSignatureNonce     = "X-Api-Nonce"
+)
+
+const (
+	DefaultSignatureMaxClockSkew = 5 * time.Minute
+	DefaultSignatureMaxBodyBytes = 1 << 20
+	nonceSize                    = 16
+)
+
+var (
+	ErrInvalidSignature    = errors.New("apikey: invalid request signature")
+	ErrStaleSignature      = errors.New("apikey: stale request signature")
+	ErrRequestBodyTooLarge = errors.New("apikey: request body too large to verify")
+	ErrReplayedSignature   = errors.New("apikey: replayed request signature")
+)
+
+// RequestSigningOptions configures HMAC request signing: instead of sending the key, clients send
+// an HMAC-SHA256 signature of the request, computed with the key as the shared secret
+// over the timestamp, method, path, query, signed headers and the SHA-256 hash of the body.
+// Signatures can only be verified against keys with a plaintext secret.
+type RequestSigningOptions struct {
+	// SignedHeaders lists the request headers included in the signature, in order.
+	SignedHeaders []string
+	// MaxClockSkew is the maximum difference between the signature timestamp and the current time;
+	// defaults to DefaultSignatureMaxClockSkew.
+	MaxClockSkew time.Duration
+	// MaxBodyBytes caps the size of request bodies read for hashing; defaults to DefaultSignatureMaxBodyBytes.
+	MaxBodyBytes int64
+	// NonceStore, when set, rejects signatures that have already been used within the allowed clock skew.
+	NonceStore NonceStore
+}
+
+func (o RequestSigningOptions) maxClockSkew() time.Duration {
+	if o.MaxClockSkew <= 0 {
+		return DefaultSignatureMaxClockSkew
+	}
+	return o.MaxClockSkew
+}
+
+func (o RequestSigningOptions) maxBodyBytes() int64 {
+	if o.MaxBodyBytes <= 0 {
+		return DefaultSignatureMaxBodyBytes
+	}
+	return o.MaxBodyBytes
+}
+
+// remember records the signature in the nonce store, reporting false when it has been used before.
+// Signatures expire from the store right after the last instant their timestamp is within the allowed clock skew.
+func (o RequestSigningOptions) remember(s RequestSignature, now time.Time) bool {
+	if o.NonceStore == nil {
+		return true
+	}
+	return o.NonceStore.Remember(hex.EncodeToString(s.Signature), now, s.Timestamp.Add(o.maxClockSkew()+time.Nanosecond))
+}
+
+// RequestSignature is the signature presented by a request, along with the payload it must have been computed over.
+type RequestSignature struct {
+	KeyID     string
+	Nonce     string
+	Timestamp time.Time
+	Signature []byte
+	Payload   []byte
+}
+
+// Verify reports whether the signature has been computed with the given secret.
+func (s RequestSignature) Verify(secret string) bool {
+	return secret != "" && hmac.Equal(s.Signature, computeSignature(secret, s.Payload))
+}
+
+// SignRequest signs the request with the given secret, setting the signature headers.
+// A random nonce is included, so that identical requests signed within the same second can be told apart from replays.
+// The body is read and replaced, so that it can still be sent.
+func SignRequest(r *http.Request, keyID, secret string, options RequestSigningOptions, now time.Time) error {
+	body, r2, err := readBody(r, -1)
+	if err != nil {
+		return err
+	}
+	r.Body = r2.Body
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set(HeaderNameSignatureTimestamp, timestamp)
+	r.Header.Set(HeaderNameSignatureNonce, hex.EncodeToString(nonce))
+	if keyID != "" {
+		r.Header.Set(HeaderNameSignatureKeyID, keyID)
+	}
+	payload := signaturePayload(r, timestamp, options.SignedHeaders, body)
+	r.Header.Set(HeaderNameSignature, hex.EncodeToString(computeSignature(secret, payload)))
+	return nil
+}
+
+// ExtractSignature reads the signature of the request, rejecting stale timestamps and oversized bodies.
+// The returned request carries a copy of the body that was read for hashing.
+func (o RequestSigningOptions) ExtractSignature(r *http.Request, now time.Time) (RequestSignature, *http.Request, error) {
+	encoded := r.Header.Get(HeaderNameSignature)
+	timestamp := r.Header.Get(HeaderNameSignatureTimestamp)
+	if encoded == "" && timestamp == "" {
+		return RequestSignature{}, r, ErrMissingCredential
+	}
+	signature, err := hex.DecodeString(encoded)
+	if err != nil || len(signature) == 0 {
+		return RequestSignature{}, r, ErrMalformedCredential
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return RequestSignature{}, r, ErrMalformedCredential
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := now.Sub(signedAt).Abs(); skew > o.maxClockSkew() {
+		return RequestSignature{}, r, fmt.Errorf("%w: signed at %s", ErrStaleSignature, signedAt.UTC().Format(time.RFC3339))
+	}
+	body, r, err := readBody(r, o.maxBodyBytes())
+	if err != nil {
+		return RequestSignature{}, r, bodyError(err)
+	}
+	return RequestSignature{
+		KeyID:     r.Header.Get(HeaderNameSignatureKeyID),
+		Nonce:     r.Header.Get(HeaderNameSignatureNonce),
+		Timestamp: signedAt,
+		Signature: signature,
+		Payload:   signaturePayload(r, timestamp, o.SignedHeaders, body),
+	}, r, nil
+}
+
+// readBody reads the request body, up to limit bytes when limit is not negative,
+// and returns a request carrying a copy of it.
+func readBody(r *http.Request, limit int64) ([]byte, *http.Request, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, r, nil
+	}
+	reader := io.Reader(r.Body)
+	if limit >= 0 {
+		reader = io.LimitReader(r.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, r, err
+	}
+	if limit >= 0 && int64(len(body)) > limit {
+		return nil, r, ErrRequestBodyTooLarge
+	}
+	r2 := r.WithContext(r.Context())
+	r2.Body = io.NopCloser(bytes.NewReader(body))
+	return body, r2, nil
+}
+
+// bodyError maps the errors of reading a request body to credential errors, so that a client disconnecting
+// or a body rejected by an outer size limit is not reported as an invalid key.
+func bodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrRequestBodyTooLarge):
+		return err
+	case errors.As(err, &maxBytesErr):
+		return fmt.Errorf("%w: %w", ErrRequestBodyTooLarge, err)
+	default:
+		return fmt.Errorf("%w: reading request body: %w", ErrMalformedCredential, err)
+	}
+}
+
+// signaturePayload builds the string to sign:
+//
+//	<timestamp>\n[<nonce>\n]<METHOD>\n<escaped path>\n<raw query>\n<header>:<value>\n...<hex SHA-256 of body>
+func signaturePayload(r *http.Request, timestamp string, signedHeaders []string, body []byte) []byte {
+	var b strings.Builder
+	b.WriteString(timestamp + "\n")
+	if nonce := r.Header.Get(HeaderNameSignatureNonce); nonce != "" {
+		b.WriteString(nonce + "\n")
+	}
+	b.WriteString(strings.ToUpper(r.Method) + "\n")
+	b.WriteString(r.URL.EscapedPath() + "\n")
+	b.WriteString(r.URL.RawQuery + "\n")
+	for _, name := range signedHeaders {
+		b.WriteString(strings.ToLower(name) + ":" + strings.TrimSpace(r.Header.Get(name)) + "\n")
+	}
+	bodyHash := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+	return []byte(b.String())
+}
+
+func computeSignature(secret string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,290 @@
+package apikey
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/chi-api-key-auth/apikeytest"
+)
+
+func TestRequestSigningOptions_ExtractSignature(t *testing.T) {
+	t.Parallel()
+
+	options := RequestSigningOptions{SignedHeaders: []string{"Content-Type"}, MaxBodyBytes: 16}
+	now := time.Unix(1740787200, 0)
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/orders?dry_run=1", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	t.Run("valid signature", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRequest(`{"id":1}`)
+		require.NoError(t, SignRequest(r, "billing", "shared-secret", options, now))
+
+		signature, r, err := options.ExtractSignature(r, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, "billing", signature.KeyID)
+		assert.Equal(t, now, signature.Timestamp)
+		assert.True(t, signature.Verify("shared-secret"))
+		assert.False(t, signature.Verify("other-secret"))
+		assert.False(t, signature.Verify(""))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":1}`, string(body), "Should keep the body readable")
+	})
+
+	t.Run("tampered request", func(t *testing.T) {
+		t.Parallel()
+
+		for name, tamper := range map[string]func(r *http.Request) *http.Request{
+			"body": func(r *http.Request) *http.Request {
+				r.Body = io.NopCloser(strings.NewReader(`{"id":2}`))
+				return r
+			},
+			"path": func(r *http.Request) *http.Request {
+				r.URL.Path = "/refunds"
+				return r
+			},
+			"query": func(r *http.Request) *http.Request {
+				r.URL.RawQuery = "dry_run=0"
+				return r
+			},
+			"method": func(r *http.Request) *http.Request {
+				r.Method = http.MethodPut
+				return r
+			},
+			"nonce": func(r *http.Request) *http.Request {
+				r.Header.Set(HeaderNameSignatureNonce, "00")
+				return r
+			},
+			"signed header": func(r *http.Request) *http.Request {
+				r.Header.Set("Content-Type", "text/plain")
+				return r
+			},
+		} {
+			r := newRequest(`{"id":1}`)
+			require.NoError(t, SignRequest(r, "", "shared-secret", options, now))
+
+			signature, _, err := options.ExtractSignature(tamper(r), now)
+			require.NoError(t, err, name)
+			assert.False(t, signature.Verify("shared-secret"), name)
+		}
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRequest("")
+		require.NoError(t, SignRequest(r, "", "shared-secret", options, now))
+
+		_, _, err := options.ExtractSignature(r, now.Add(DefaultSignatureMaxClockSkew+time.Second))
+		require.ErrorIs(t, err, ErrStaleSignature)
+		_, _, err = options.ExtractSignature(r, now.Add(-DefaultSignatureMaxClockSkew-time.Second))
+		require.ErrorIs(t, err, ErrStaleSignature)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRequest(strings.Repeat("x", 17))
+		require.NoError(t, SignRequest(r, "", "shared-secret", options, now))
+
+		_, _, err := options.ExtractSignature(r, now)
+		require.ErrorIs(t, err, ErrRequestBodyTooLarge)
+	})
+
+	t.Run("body read errors", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRequest("")
+		require.NoError(t, SignRequest(r, "", "shared-secret", options, now))
+
+		r.Body = io.NopCloser(iotest.ErrReader(io.ErrUnexpectedEOF))
+		_, _, err := options.ExtractSignature(r, now)
+		require.ErrorIs(t, err, ErrMalformedCredential, "Should not report a client disconnect as an invalid key")
+		assert.Equal(t, FailureReasonMalformedCredential, FailureReasonFromError(err))
+
+		r.Body = http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader("xxxx")), 2)
+		_, _, err = options.ExtractSignature(r, now)
+		require.ErrorIs(t, err, ErrRequestBodyTooLarge, "Should report bodies rejected by an outer limit as too large")
+	})
+
+	t.Run("missing and malformed headers", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := options.ExtractSignature(newRequest(""), now)
+		require.ErrorIs(t, err, ErrMissingCredential)
+
+		r := newRequest("")
+		r.Header.Set(HeaderNameSignature, "not-hex")
+		r.Header.Set(HeaderNameSignatureTimestamp, strconv.FormatInt(now.Unix(), 10))
+		_, _, err = options.ExtractSignature(r, now)
+		require.ErrorIs(t, err, ErrMalformedCredential)
+
+		r = newRequest("")
+		r.Header.Set(HeaderNameSignature, "abcdef")
+		r.Header.Set(HeaderNameSignatureTimestamp, "yesterday")
+		_, _, err = options.ExtractSignature(r, now)
+		require.ErrorIs(t, err, ErrMalformedCredential)
+	})
+}
+
+func TestAuthorize_RequestSigning(t *testing.T) {
+	hashed, err := NewSHA256KeyHash("hashed-secret")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(Authorize(Options{
+		KeySetProvider: StaticKeySet{
+			{ID: "billing", Secret: "billing-secret", Scope: PermissionScopeReadWrite},
+			{ID: "reporting", Secret: "reporting-secret", Scope: PermissionScopeReadWrite},
+			{ID: "hashed", Hash: hashed, Scope: PermissionScopeReadWrite},
+		},
+		RequestSigning: &RequestSigningOptions{MaxBodyBytes: 64},
+		FailureHandler: ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		p, _ := PrincipalFromContext(r.Context())
+		_, _ = w.Write([]byte(p.KeyID + ":" + string(body)))
+	})))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		keyID      string
+		secret     string
+		body       string
+		signedAt   time.Time
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "valid signature", secret: "reporting-secret", body: "report", wantStatus: http.StatusOK, wantBody: "reporting:report"},
+		{name: "valid signature with key ID", keyID: "billing", secret: "billing-secret", body: "invoice", wantStatus: http.StatusOK, wantBody: "billing:invoice"},
+		{name: "signature of another key ID", keyID: "billing", secret: "reporting-secret", wantStatus: http.StatusUnauthorized, wantBody: `"reason":"invalid_signature"`},
+		{name: "unknown secret", secret: "unknown-secret", wantStatus: http.StatusUnauthorized, wantBody: `"reason":"invalid_signature"`},
+		{name: "hashed key", secret: "hashed-secret", wantStatus: http.StatusUnauthorized, wantBody: `"reason":"invalid_signature"`},
+		{name: "stale signature", secret: "billing-secret", signedAt: time.Now().Add(-time.Hour), wantStatus: http.StatusUnauthorized, wantBody: `"reason":"stale_signature"`},
+		{name: "body too large", secret: "billing-secret", body: strings.Repeat("x", 65), wantStatus: http.StatusRequestEntityTooLarge, wantBody: `"reason":"request_too_large"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/events?source=test", strings.NewReader(tt.body))
+			require.NoError(t, err)
+			signedAt := tt.signedAt
+			if signedAt.IsZero() {
+				signedAt = time.Now()
+			}
+			require.NoError(t, SignRequest(req, tt.keyID, tt.secret, RequestSigningOptions{}, signedAt))
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = resp.Body.Close()
+			})
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Contains(t, string(data), tt.wantBody)
+		})
+	}
+}
+
+func TestAuthorize_RequestSigning_Replay(t *testing.T) {
+	srv := httptest.NewServer(Authorize(Options{
+		KeySetProvider: StaticKeySet{{ID: "billing", Secret: "billing-secret", Scope: PermissionScopeReadWrite}},
+		RequestSigning: &RequestSigningOptions{NonceStore: NewMemoryNonceStore()},
+		FailureHandler: ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	defer srv.Close()
+
+	send := func(req *http.Request) (int, string) {
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+	newSignedRequest := func(secret string) *http.Request {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL+"/events", strings.NewReader("event"))
+		require.NoError(t, err)
+		require.NoError(t, SignRequest(req, "", secret, RequestSigningOptions{}, time.Now()))
+		return req
+	}
+
+	req := newSignedRequest("billing-secret")
+	status, _ := send(req)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	replay := req.Clone(context.Background())
+	replay.Body = io.NopCloser(strings.NewReader("event"))
+	status, body := send(replay)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Contains(t, body, `"reason":"replayed_signature"`)
+
+	status, _ = send(newSignedRequest("billing-secret"))
+	assert.Equal(t, http.StatusNoContent, status, "Should accept an identical request signed with a new nonce")
+
+	invalid := newSignedRequest("unknown-secret")
+	status, body = send(invalid)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Contains(t, body, `"reason":"invalid_signature"`, "Should not record signatures that fail verification")
+}
+
+func TestAuthorize_RequestSigning_Replay_Clock(t *testing.T) {
+	t.Parallel()
+
+	signedAt := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	clock := apikeytest.NewClock(signedAt)
+	handler := Authorize(Options{
+		KeySetProvider: StaticKeySet{{ID: "billing", Secret: "billing-secret", Scope: PermissionScopeReadWrite}},
+		RequestSigning: &RequestSigningOptions{NonceStore: NewMemoryNonceStore()},
+		Clock:          clock,
+		FailureHandler: ProblemDetailsHandler(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader("event"))
+	require.NoError(t, SignRequest(req, "", "billing-secret", RequestSigningOptions{}, signedAt))
+	send := func() *httptest.ResponseRecorder {
+		replay := req.Clone(context.Background())
+		replay.Body = io.NopCloser(strings.NewReader("event"))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, replay)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, send().Code)
+	w := send()
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Should expire nonces with the clock of the middleware")
+	assert.Contains(t, w.Body.String(), `"reason":"replayed_signature"`)
+
+	clock.Set(signedAt.Add(DefaultSignatureMaxClockSkew))
+	w = send()
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Should keep nonces for as long as their timestamp is accepted")
+	assert.Contains(t, w.Body.String(), `"reason":"replayed_signature"`)
+
+	clock.Advance(time.Second)
+	w = send()
+	assert.Contains(t, w.Body.String(), `"reason":"stale_signature"`)
+}
@@ -0,0 +1,38 @@
+package apikey
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/georgepsarakis/chi-api-key-auth"
+
+const spanNameAuthorize = "apikey.Authorize"
+
+// Span attribute keys and event names recorded by the Authorize middleware.
+const (
+	AttributeOutcome       = attribute.Key("apikey.outcome")
+	AttributeKeyID         = attribute.Key("apikey.key_id")
+	AttributeScope         = attribute.Key("apikey.scope")
+	AttributeFailureReason = attribute.Key("apikey.failure_reason")
+	EventDeprecatedKeyUsed = "apikey.deprecated_key_used"
+)
+
+func recordDecision(span trace.Span, decision Decision) {
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(AttributeOutcome.String(decision.Outcome.String()))
+	if decision.Key.ID != "" {
+		span.SetAttributes(
+			AttributeKeyID.String(decision.Key.ID),
+			AttributeScope.String(string(decision.Key.Scope)),
+		)
+	}
+	if decision.Reason != "" {
+		span.SetAttributes(AttributeFailureReason.String(string(decision.Reason)))
+	}
+	if decision.Outcome == OutcomeAuthenticated && decision.Key.Deprecated {
+		span.AddEvent(EventDeprecatedKeyUsed, trace.WithAttributes(AttributeKeyID.String(decision.Key.ID)))
+	}
+}
@@ -0,0 +1,80 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAuthorize_Tracing(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider: StaticKeySet{
+			{ID: "current", Secret: "current-key", Scope: PermissionScopeReadWrite},
+			{ID: "deprecated", Secret: "deprecated-key", Scope: PermissionScopeReadWrite, Deprecated: true, ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		TracerProvider: provider,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, requestKey := range []string{"current-key", "deprecated-key", "wrong-key"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderNameXApiKey, requestKey)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	for _, span := range spans {
+		assert.Equal(t, spanNameAuthorize, span.Name)
+	}
+
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		AttributeOutcome.String("authenticated"),
+		AttributeKeyID.String("current"),
+		AttributeScope.String("readwrite"),
+	}, spans[0].Attributes)
+	assert.Empty(t, spans[0].Events)
+
+	require.Len(t, spans[1].Events, 1)
+	assert.Equal(t, EventDeprecatedKeyUsed, spans[1].Events[0].Name)
+	assert.Equal(t, []attribute.KeyValue{AttributeKeyID.String("deprecated")}, spans[1].Events[0].Attributes)
+
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		AttributeOutcome.String("unauthenticated"),
+		AttributeFailureReason.String("invalid_key"),
+	}, spans[2].Attributes)
+}
+
+func TestAuthorize_Tracing_NoTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	handler := Authorize(Options{
+		HeaderAuthProvider: XApiKeyHeader{},
+		KeySetProvider:     StaticKeySet{{ID: "current", Secret: "current-key", Scope: PermissionScopeReadWrite}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderNameXApiKey, "current-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
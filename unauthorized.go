@@ -2,24 +2,127 @@ package apikey
 
 import (
 	"context"
+	"errors"
 	"net/http"
 )
 
+// FailureReason is a machine-readable reason for rejecting a request.
+type FailureReason string
+
+const (
+	FailureReasonMissingCredential   = FailureReason("missing_credential")
+	FailureReasonMalformedCredential = FailureReason("malformed_credential")
+	FailureReasonAmbiguousCredential = FailureReason("ambiguous_credential")
+	FailureReasonInvalidKey          = FailureReason("invalid_key")
+	FailureReasonKeyExpired          = FailureReason("key_expired")
+	FailureReasonKeyNotYetValid      = FailureReason("key_not_yet_valid")
+	FailureReasonInsufficientScope   = FailureReason("insufficient_scope")
+	FailureReasonAddressNotAllowed   = FailureReason("address_not_allowed")
+	FailureReasonInvalidSignature    = FailureReason("invalid_signature")
+	FailureReasonStaleSignature      = FailureReason("stale_signature")
+	FailureReasonReplayedSignature   = FailureReason("replayed_signature")
+	FailureReasonRequestTooLarge     = FailureReason("request_too_large")
+	FailureReasonRateLimited         = FailureReason("rate_limited")
+	FailureReasonQuotaExceeded       = FailureReason("quota_exceeded")
+	FailureReasonLockedOut           = FailureReason("locked_out")
+)
+
+// FailureReasonFromError maps the errors returned by SecretExtractor and Authorizer implementations to a failure reason.
+// Unknown errors are reported as malformed credentials, so that they are neither challenged as invalid tokens
+// nor counted as guesses towards a lockout.
+func FailureReasonFromError(err error) FailureReason {
+	switch {
+	case errors.Is(err, ErrInvalidKey):
+		return FailureReasonInvalidKey
+	case errors.Is(err, ErrMissingCredential):
+		return FailureReasonMissingCredential
+	case errors.Is(err, ErrMalformedCredential):
+		return FailureReasonMalformedCredential
+	case errors.Is(err, ErrAmbiguousCredential):
+		return FailureReasonAmbiguousCredential
+	case errors.Is(err, ErrKeyExpired):
+		return FailureReasonKeyExpired
+	case errors.Is(err, ErrKeyNotYetValid):
+		return FailureReasonKeyNotYetValid
+	case errors.Is(err, ErrInsufficientScope):
+		return FailureReasonInsufficientScope
+	case errors.Is(err, ErrAddressNotAllowed):
+		return FailureReasonAddressNotAllowed
+	case errors.Is(err, ErrInvalidSignature):
+		return FailureReasonInvalidSignature
+	case errors.Is(err, ErrStaleSignature):
+		return FailureReasonStaleSignature
+	case errors.Is(err, ErrReplayedSignature):
+		return FailureReasonReplayedSignature
+	case errors.Is(err, ErrRequestBodyTooLarge):
+		return FailureReasonRequestTooLarge
+	case errors.Is(err, ErrLockedOut):
+		return FailureReasonLockedOut
+	default:
+		return FailureReasonMalformedCredential
+	}
+}
+
 type ctxKey struct{}
 
-var unauthorizedContextKey = ctxKey{} //nolint:gochecknoglobals
+type failureReasonCtxKey struct{}
+
+var unauthorizedContextKey = ctxKey{}               //nolint:gochecknoglobals
+var failureReasonContextKey = failureReasonCtxKey{} //nolint:gochecknoglobals
 
 func NewUnauthorizedContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, unauthorizedContextKey, true)
 }
 
+// NewFailureContext records the reason a request has been rejected for.
+func NewFailureContext(ctx context.Context, reason FailureReason) context.Context {
+	return context.WithValue(ctx, failureReasonContextKey, reason)
+}
+
+func FailureReasonFromContext(ctx context.Context) (FailureReason, bool) {
+	reason, ok := ctx.Value(failureReasonContextKey).(FailureReason)
+	return reason, ok
+}
+
+// IsUnauthorized reports whether the request presented a credential that has been rejected by Authorize.
+// It is false for missing or malformed credentials, and for rejections by other middlewares,
+// such as RequireScopes, rate limits and quotas; FailureReasonFromContext covers all of them.
 func IsUnauthorized(ctx context.Context) bool {
 	unauthorized, ok := ctx.Value(unauthorizedContextKey).(bool)
 	return ok && unauthorized
 }
 
+// newRejectionContext records the failure reason of a request rejected by Authorize,
+// along with the unauthorized flag when a presented credential has been rejected.
+func newRejectionContext(ctx context.Context, reason FailureReason) context.Context {
+	ctx = NewFailureContext(ctx, reason)
+	if reason.rejectsCredential() {
+		ctx = NewUnauthorizedContext(ctx)
+	}
+	return ctx
+}
+
+// rejectsCredential reports whether the failure is the rejection of a presented, well-formed credential.
+func (r FailureReason) rejectsCredential() bool {
+	switch r {
+	case FailureReasonInvalidKey, FailureReasonKeyExpired, FailureReasonKeyNotYetValid, FailureReasonInsufficientScope,
+		FailureReasonAddressNotAllowed, FailureReasonInvalidSignature, FailureReasonStaleSignature, FailureReasonReplayedSignature:
+		return true
+	case FailureReasonMissingCredential, FailureReasonMalformedCredential, FailureReasonAmbiguousCredential,
+		FailureReasonRequestTooLarge, FailureReasonRateLimited, FailureReasonQuotaExceeded, FailureReasonLockedOut:
+		return false
+	}
+	return false
+}
+
 func DefaultUnauthorizedHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
 	}
 }
+
+func DefaultForbiddenHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}
+}
@@ -2,6 +2,8 @@ package apikey
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +42,38 @@ func TestIsUnauthorized(t *testing.T) {
 		})
 	}
 }
+
+func TestFailureReasonFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := FailureReasonFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := NewFailureContext(context.Background(), FailureReasonKeyExpired)
+	reason, ok := FailureReasonFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, FailureReasonKeyExpired, reason)
+	assert.False(t, IsUnauthorized(ctx), "Should only be set by Authorize for rejected credentials")
+}
+
+func TestFailureReasonFromError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want FailureReason
+	}{
+		{err: ErrMissingCredential, want: FailureReasonMissingCredential},
+		{err: ErrMalformedCredential, want: FailureReasonMalformedCredential},
+		{err: ErrInvalidKey, want: FailureReasonInvalidKey},
+		{err: fmt.Errorf("%w: key-id", ErrKeyExpired), want: FailureReasonKeyExpired},
+		{err: fmt.Errorf("%w: key-id", ErrInsufficientScope), want: FailureReasonInsufficientScope},
+		{err: errors.New("unknown"), want: FailureReasonMalformedCredential},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, FailureReasonFromError(tt.err))
+		})
+	}
+}